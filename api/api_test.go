@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+)
+
+// newQuoteServer 模拟行情接口，返回固定的行情和K线
+func newQuoteServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path+"?secid="+r.URL.Query().Get("secid"))
+		switch r.URL.Path {
+		case "/api/qt/stock/get":
+			w.Write([]byte(`{"data":{"f57":"510300","f58":"沪深300ETF","f43":3856,"f19":3855,"f39":3857}}`))
+		case "/api/qt/stock/kline/get":
+			w.Write([]byte(`{"data":{"code":"510300","klines":[
+				"2023-11-20,3.850,3.856,3.860,3.840,1000,385600.00,0.52,0.16,0.006,0.10",
+				"2023-11-21,3.856,3.870,3.880,3.850,2000,773000.00,0.78,0.36,0.014,0.20"]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &paths
+}
+
+func TestClientBaseURL(t *testing.T) {
+	ts, paths := newQuoteServer(t)
+	c := NewClient(Config{QuoteBaseURL: ts.URL + "/", KlineBaseURL: ts.URL})
+
+	q, err := c.GetQuoteContext(context.Background(), "510300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Code != "510300" || q.NewestPrice != 3.856 || q.BuyPrice1 != 3.855 || q.SalePrice1 != 3.857 {
+		t.Fatalf("行情错误: %+v", q)
+	}
+	klines, err := c.GetKline(model.QueryKlineParam{Code: "510300"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(klines) != 2 || klines[1].Date != "2023-11-21" || klines[1].Volume.IntPart() != 2000 {
+		t.Fatalf("K线错误: %+v", klines)
+	}
+	want := []string{"/api/qt/stock/get?secid=1.510300", "/api/qt/stock/kline/get?secid=1.510300"}
+	if len(*paths) != 2 || (*paths)[0] != want[0] || (*paths)[1] != want[1] {
+		t.Fatalf("请求地址错误: %v", *paths)
+	}
+}
+
+func TestNewClientDefaults(t *testing.T) {
+	c := NewClient(Config{})
+	if c.config.QuoteBaseURL != defaultQuoteBaseURL || c.config.KlineBaseURL != defaultKlineBaseURL || c.cli.Timeout == 0 {
+		t.Fatalf("默认配置错误: %+v", c.config)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	ts, paths := newQuoteServer(t)
+	old := Default()
+	defer SetDefault(old)
+	SetDefault(NewClient(Config{QuoteBaseURL: ts.URL, KlineBaseURL: ts.URL}))
+
+	if _, err := GetQuote("159915"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetKlineContext(context.Background(), model.QueryKlineParam{Code: "159915"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*paths) != 2 {
+		t.Fatalf("包级别的函数应该使用 SetDefault 设置的客户端，实际请求了 %v", *paths)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	ts, paths := newQuoteServer(t)
+	c := NewClient(Config{QuoteBaseURL: ts.URL, KlineBaseURL: ts.URL})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetQuoteContext(ctx, "510300"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("应该返回 context.Canceled，实际为 %v", err)
+	}
+	if _, err := c.GetKlineContext(ctx, model.QueryKlineParam{Code: "510300"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("应该返回 context.Canceled，实际为 %v", err)
+	}
+	if len(*paths) != 0 {
+		t.Fatalf("取消的请求不应该发出: %v", *paths)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQuoteBaseURL = "http://push2.eastmoney.com"
+	defaultKlineBaseURL = "http://push2his.eastmoney.com"
+)
+
+// Config 行情接口配置，测试时可以将地址指向本地的模拟服务
+type Config struct {
+	// 实时行情地址，默认为 http://push2.eastmoney.com
+	QuoteBaseURL string
+	// 历史K线地址，默认为 http://push2his.eastmoney.com
+	KlineBaseURL string
+	// 请求超时时间，默认3秒
+	Timeout time.Duration
+}
+
+// Client 行情接口客户端
+type Client struct {
+	config Config
+	cli    *http.Client
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultClient = NewClient(Config{})
+)
+
+// SetDefault 设置包级别的 GetQuote、GetKline 等函数使用的客户端。
+// risk、conditional、execution 等包没有指定行情函数时使用的也是包级别的函数，
+// 因此设置后整个库的行情请求都会发往新的地址
+func SetDefault(c *Client) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultClient = c
+}
+
+// Default 包级别的函数使用的客户端
+func Default() *Client {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultClient
+}
+
+// NewClient 创建行情客户端，未设置的配置项使用默认值
+func NewClient(c Config) *Client {
+	c.QuoteBaseURL = strings.TrimSuffix(c.QuoteBaseURL, "/")
+	c.KlineBaseURL = strings.TrimSuffix(c.KlineBaseURL, "/")
+	if c.QuoteBaseURL == "" {
+		c.QuoteBaseURL = defaultQuoteBaseURL
+	}
+	if c.KlineBaseURL == "" {
+		c.KlineBaseURL = defaultKlineBaseURL
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 3 * time.Second
+	}
+	return &Client{
+		config: c,
+		cli:    &http.Client{Timeout: c.Timeout},
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -41,9 +42,23 @@ func createDefaultKlineQuery(q model.QueryKlineParam) model.QueryKlineParam {
 // GetKline 获取K线数据
 // 当K线数据为1分钟K线时，Begin 和 End 不起作用，仅仅能获取最近一个交易日的数据，无法获取历史数据
 func GetKline(q model.QueryKlineParam) ([]*model.Kline, error) {
+	return Default().GetKline(q)
+}
+
+// GetKlineContext 获取K线数据
+func GetKlineContext(ctx context.Context, q model.QueryKlineParam) ([]*model.Kline, error) {
+	return Default().GetKlineContext(ctx, q)
+}
+
+// GetKline 获取K线数据
+func (c *Client) GetKline(q model.QueryKlineParam) ([]*model.Kline, error) {
+	return c.GetKlineContext(context.Background(), q)
+}
+
+// GetKlineContext 获取K线数据
+func (c *Client) GetKlineContext(ctx context.Context, q model.QueryKlineParam) ([]*model.Kline, error) {
 	param := createDefaultKlineQuery(q)
-	var client = http.Client{}
-	req, _ := http.NewRequest("GET", "http://push2his.eastmoney.com/api/qt/stock/kline/get", nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", c.config.KlineBaseURL+"/api/qt/stock/kline/get", nil)
 	query := req.URL.Query()
 	query.Add("fields1", "f1,f2,f3,f4,f5,f6,f7,f8,f9,f10,f11,f12,f13")
 	query.Add("fields2", "f51,f52,f53,f54,f55,f56,f57,f58,f59,f60,f61")
@@ -55,7 +70,7 @@ func GetKline(q model.QueryKlineParam) ([]*model.Kline, error) {
 	// 前复权
 	query.Add("fqt", "1")
 	req.URL.RawQuery = query.Encode()
-	resp, err := client.Do(req)
+	resp, err := c.cli.Do(req)
 	if err != nil {
 		return nil, err
 	}
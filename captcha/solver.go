@@ -0,0 +1,421 @@
+package captcha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// 东财登录验证码固定为4位数字
+const codeLength = 4
+
+// 单个数字归一化后的尺寸
+const (
+	cellWidth  = 12
+	cellHeight = 16
+)
+
+// Solver 纯Go实现的验证码识别器
+// 识别过程：二值化 -> 去噪 -> 按列投影切分出4个数字 -> 与每个数字的模板比较，取距离最近的模板
+type Solver struct {
+	templates [10][]float64
+}
+
+// Sample 已标注的验证码样本
+type Sample struct {
+	Image []byte
+	Code  string
+}
+
+type templateFile struct {
+	Width  int                  `json:"width"`
+	Height int                  `json:"height"`
+	Digits map[string][]float64 `json:"digits"`
+}
+
+// Train 根据已标注的样本生成模板，每个数字的模板是所有对应切片的平均值
+func Train(samples []Sample) (*Solver, error) {
+	var sum [10][]float64
+	var count [10]int
+	for _, sample := range samples {
+		cells, err := extract(sample.Image)
+		if err != nil {
+			continue
+		}
+		if len(sample.Code) != codeLength {
+			continue
+		}
+		for i, cell := range cells {
+			d := int(sample.Code[i] - '0')
+			if d < 0 || d > 9 {
+				break
+			}
+			if sum[d] == nil {
+				sum[d] = make([]float64, cellWidth*cellHeight)
+			}
+			for j := range cell {
+				sum[d][j] += cell[j]
+			}
+			count[d]++
+		}
+	}
+
+	s := &Solver{}
+	for d := range sum {
+		if count[d] == 0 {
+			return nil, errors.Errorf("样本中缺少数字 %d", d)
+		}
+		for j := range sum[d] {
+			sum[d][j] /= float64(count[d])
+		}
+		s.templates[d] = sum[d]
+	}
+	return s, nil
+}
+
+// LoadSamples 读取目录下的验证码样本，文件名的前4位为验证码，如 1234.png、1234_01.png
+func LoadSamples(dir string) ([]Sample, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var samples []Sample
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) < codeLength {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, Sample{Image: data, Code: name[:codeLength]})
+	}
+	return samples, nil
+}
+
+// Load 读取 Save 保存的模板
+func Load(r io.Reader) (*Solver, error) {
+	var f templateFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	if f.Width != cellWidth || f.Height != cellHeight {
+		return nil, errors.Errorf("模板尺寸不匹配: %dx%d", f.Width, f.Height)
+	}
+	s := &Solver{}
+	for d := 0; d < 10; d++ {
+		t, ok := f.Digits[string(rune('0'+d))]
+		if !ok || len(t) != cellWidth*cellHeight {
+			return nil, errors.Errorf("模板中缺少数字 %d", d)
+		}
+		s.templates[d] = t
+	}
+	return s, nil
+}
+
+// LoadFile 从文件读取模板
+func LoadFile(path string) (*Solver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Save 保存模板
+func (s *Solver) Save(w io.Writer) error {
+	f := templateFile{
+		Width:  cellWidth,
+		Height: cellHeight,
+		Digits: make(map[string][]float64, 10),
+	}
+	for d := range s.templates {
+		// 保留4位小数，足够区分并且减小文件体积
+		t := make([]float64, len(s.templates[d]))
+		for j, v := range s.templates[d] {
+			t[j] = math.Round(v*1e4) / 1e4
+		}
+		f.Digits[string(rune('0'+d))] = t
+	}
+	return json.NewEncoder(w).Encode(f)
+}
+
+// Recognize 识别验证码，识别在本地完成，ctx 仅用于满足 client.CaptchaRecognizer 接口
+func (s *Solver) Recognize(ctx context.Context, img []byte) (string, error) {
+	cells, err := extract(img)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, cell := range cells {
+		best, bestDist := -1, math.MaxFloat64
+		for d, t := range s.templates {
+			if t == nil {
+				continue
+			}
+			var dist float64
+			for j := range cell {
+				diff := cell[j] - t[j]
+				dist += diff * diff
+			}
+			if dist < bestDist {
+				best, bestDist = d, dist
+			}
+		}
+		if best < 0 {
+			return "", errors.New("未加载验证码模板")
+		}
+		sb.WriteByte(byte('0' + best))
+	}
+	return sb.String(), nil
+}
+
+// Evaluate 计算识别准确率（整串验证码完全正确才算正确）
+func (s *Solver) Evaluate(samples []Sample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var hit int
+	for _, sample := range samples {
+		if code, err := s.Recognize(context.Background(), sample.Image); err == nil && code == sample.Code {
+			hit++
+		}
+	}
+	return float64(hit) / float64(len(samples))
+}
+
+// extract 将验证码图片切分为4个归一化后的数字
+func extract(data []byte) ([][]float64, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "验证码图片解码失败")
+	}
+	bin := binarize(img)
+	denoise(bin)
+
+	segments := segment(bin)
+	if len(segments) != codeLength {
+		return nil, errors.Errorf("验证码切分失败，切分出 %d 个字符", len(segments))
+	}
+	cells := make([][]float64, 0, codeLength)
+	for _, seg := range segments {
+		cells = append(cells, normalize(bin, seg[0], seg[1]))
+	}
+	return cells, nil
+}
+
+type bitmap struct {
+	w, h int
+	px   []bool
+}
+
+func (b *bitmap) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.w || y >= b.h {
+		return false
+	}
+	return b.px[y*b.w+x]
+}
+
+// binarize 灰度化后使用大津法求阈值，深色像素为前景
+func binarize(img image.Image) *bitmap {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	gray := make([]uint8, w*h)
+	var hist [256]int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			v := uint8((299*r + 587*g + 114*b) / 1000 >> 8)
+			gray[y*w+x] = v
+			hist[v]++
+		}
+	}
+
+	total := w * h
+	var sumAll float64
+	for i, c := range hist {
+		sumAll += float64(i * c)
+	}
+	var sumB, wB float64
+	var best float64
+	threshold := 128
+	for i, c := range hist {
+		wB += float64(c)
+		if wB == 0 {
+			continue
+		}
+		wF := float64(total) - wB
+		if wF == 0 {
+			break
+		}
+		sumB += float64(i * c)
+		mB := sumB / wB
+		mF := (sumAll - sumB) / wF
+		between := wB * wF * (mB - mF) * (mB - mF)
+		if between > best {
+			best = between
+			threshold = i
+		}
+	}
+
+	b := &bitmap{w: w, h: h, px: make([]bool, w*h)}
+	for i, v := range gray {
+		b.px[i] = int(v) <= threshold
+	}
+	return b
+}
+
+// denoise 去掉孤立的噪点
+func denoise(b *bitmap) {
+	var noise []int
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if !b.at(x, y) {
+				continue
+			}
+			var n int
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && b.at(x+dx, y+dy) {
+						n++
+					}
+				}
+			}
+			if n < 2 {
+				noise = append(noise, y*b.w+x)
+			}
+		}
+	}
+	for _, i := range noise {
+		b.px[i] = false
+	}
+}
+
+// segment 按列投影切分字符，返回每个字符的 [起始列, 结束列)
+// 切分结果多于4个时合并最窄的相邻片段，少于4个时从最宽的片段中投影最小的位置拆开
+func segment(b *bitmap) [][2]int {
+	proj := make([]int, b.w)
+	for x := 0; x < b.w; x++ {
+		for y := 0; y < b.h; y++ {
+			if b.at(x, y) {
+				proj[x]++
+			}
+		}
+	}
+
+	var segs [][2]int
+	start := -1
+	for x := 0; x <= b.w; x++ {
+		on := x < b.w && proj[x] > 0
+		if on && start < 0 {
+			start = x
+		}
+		if !on && start >= 0 {
+			segs = append(segs, [2]int{start, x})
+			start = -1
+		}
+	}
+
+	// 过滤掉过窄的片段，一般是残留的干扰线
+	filtered := segs[:0]
+	for _, s := range segs {
+		if s[1]-s[0] >= 2 {
+			filtered = append(filtered, s)
+		}
+	}
+	segs = filtered
+
+	for len(segs) > codeLength {
+		idx := 0
+		for i := 1; i < len(segs)-1; i++ {
+			if segs[i+1][1]-segs[i][0] < segs[idx+1][1]-segs[idx][0] {
+				idx = i
+			}
+		}
+		segs[idx][1] = segs[idx+1][1]
+		segs = append(segs[:idx+1], segs[idx+2:]...)
+	}
+
+	for len(segs) > 0 && len(segs) < codeLength {
+		idx := 0
+		for i := range segs {
+			if segs[i][1]-segs[i][0] > segs[idx][1]-segs[idx][0] {
+				idx = i
+			}
+		}
+		s := segs[idx]
+		if s[1]-s[0] < 4 {
+			break
+		}
+		// 在中间一半的区域里找投影最小的列作为拆分点
+		from, to := s[0]+(s[1]-s[0])/4, s[1]-(s[1]-s[0])/4
+		cut := (s[0] + s[1]) / 2
+		for x := from; x < to; x++ {
+			if proj[x] < proj[cut] {
+				cut = x
+			}
+		}
+		segs = append(segs[:idx], append([][2]int{{s[0], cut}, {cut, s[1]}}, segs[idx+1:]...)...)
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i][0] < segs[j][0] })
+	return segs
+}
+
+// normalize 裁剪出字符所在的区域并缩放为 cellWidth*cellHeight，每个值为对应区域前景像素的占比
+func normalize(b *bitmap, x0, x1 int) []float64 {
+	y0, y1 := b.h, 0
+	for y := 0; y < b.h; y++ {
+		for x := x0; x < x1; x++ {
+			if b.at(x, y) {
+				if y < y0 {
+					y0 = y
+				}
+				if y+1 > y1 {
+					y1 = y + 1
+				}
+			}
+		}
+	}
+	cell := make([]float64, cellWidth*cellHeight)
+	if y0 >= y1 {
+		return cell
+	}
+	w, h := x1-x0, y1-y0
+	for cy := 0; cy < cellHeight; cy++ {
+		sy0, sy1 := y0+cy*h/cellHeight, y0+(cy+1)*h/cellHeight
+		if sy1 == sy0 {
+			sy1 = sy0 + 1
+		}
+		for cx := 0; cx < cellWidth; cx++ {
+			sx0, sx1 := x0+cx*w/cellWidth, x0+(cx+1)*w/cellWidth
+			if sx1 == sx0 {
+				sx1 = sx0 + 1
+			}
+			var on, all int
+			for y := sy0; y < sy1; y++ {
+				for x := sx0; x < sx1; x++ {
+					all++
+					if b.at(x, y) {
+						on++
+					}
+				}
+			}
+			cell[cy*cellWidth+cx] = float64(on) / float64(all)
+		}
+	}
+	return cell
+}
@@ -0,0 +1,106 @@
+package captcha_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yfjiang-danny/eastmoneyapi/captcha"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+)
+
+// 测试样本的最低准确率（整串验证码完全正确）
+const minAccuracy = 0.95
+
+// 标注好的真实验证码样本，文件名前4位为验证码，train 用于训练，test 用于测量准确率
+var realSampleDir = filepath.Join("testdata", "jywg")
+
+// syntheticSamples 由模拟服务生成的验证码样本
+func syntheticSamples(n int, seed int64) []captcha.Sample {
+	rnd := rand.New(rand.NewSource(seed))
+	samples := make([]captcha.Sample, 0, n)
+	for i := 0; i < n; i++ {
+		code := fmt.Sprintf("%04d", rnd.Intn(10000))
+		samples = append(samples, captcha.Sample{Image: fakebroker.RenderCaptcha(code, rnd), Code: code})
+	}
+	return samples
+}
+
+// TestSyntheticAccuracy 只验证切分和模板匹配的流程：训练和测试样本都是模拟服务生成的图片，
+// 结果不代表真实验证码的准确率
+func TestSyntheticAccuracy(t *testing.T) {
+	s, err := captcha.Train(syntheticSamples(300, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc := s.Evaluate(syntheticSamples(60, 2)); acc < minAccuracy {
+		t.Fatalf("准确率 %.2f%% 低于 %.2f%%", acc*100, minAccuracy*100)
+	}
+}
+
+// TestRealSampleAccuracy 用标注好的真实验证码测量准确率，没有样本时跳过
+func TestRealSampleAccuracy(t *testing.T) {
+	train, err := captcha.LoadSamples(filepath.Join(realSampleDir, "train"))
+	if os.IsNotExist(err) {
+		t.Skipf("%s 下没有真实验证码样本", realSampleDir)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := captcha.LoadSamples(filepath.Join(realSampleDir, "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := captcha.Train(train)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := s.Evaluate(test)
+	t.Logf("真实验证码 %d 个，准确率 %.2f%%", len(test), acc*100)
+	if acc < minAccuracy {
+		t.Fatalf("准确率 %.2f%% 低于 %.2f%%", acc*100, minAccuracy*100)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	s, err := captcha.Train(syntheticSamples(100, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := captcha.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range syntheticSamples(10, 3) {
+		want, _ := s.Recognize(context.Background(), sample.Image)
+		got, err := loaded.Recognize(context.Background(), sample.Image)
+		if err != nil || got != want {
+			t.Fatalf("%s: 重新加载后识别为 %q，原模板为 %q，err=%v", sample.Code, got, want, err)
+		}
+	}
+}
+
+func TestTrainMissingDigit(t *testing.T) {
+	samples := []captcha.Sample{{Image: fakebroker.RenderCaptcha("1111", rand.New(rand.NewSource(1))), Code: "1111"}}
+	if _, err := captcha.Train(samples); err == nil {
+		t.Fatal("样本中缺少数字时应该返回错误")
+	}
+}
+
+func TestRecognizeInvalidImage(t *testing.T) {
+	s, err := captcha.Train(syntheticSamples(100, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Recognize(context.Background(), []byte("not an image")); err == nil {
+		t.Fatal("无效的图片应该返回错误")
+	}
+}
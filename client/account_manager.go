@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// AccountManager 管理多个资金账户的客户端，通过名称访问
+type AccountManager struct {
+	mu      sync.RWMutex
+	clients map[string]*EastMoneyClient
+	// 正在登录的账户，登录不持有锁，用它防止同名的账户同时登录
+	adding map[string]bool
+}
+
+func NewAccountManager() *AccountManager {
+	return &AccountManager{
+		clients: make(map[string]*EastMoneyClient),
+		adding:  make(map[string]bool),
+	}
+}
+
+// Add 为账户创建客户端并登录，名称不能重复
+func (m *AccountManager) Add(name string, c EastMoneyClientConfig) (*EastMoneyClient, error) {
+	return m.AddContext(context.Background(), name, c)
+}
+
+// AddContext 为账户创建客户端并登录，ctx 用于取消登录。
+// 登录（包括验证码识别和重试）期间不会阻塞其他账户的 Get、Names、Remove
+func (m *AccountManager) AddContext(ctx context.Context, name string, c EastMoneyClientConfig) (*EastMoneyClient, error) {
+	m.mu.Lock()
+	if _, ok := m.clients[name]; ok || m.adding[name] {
+		m.mu.Unlock()
+		return nil, errors.New("账户已存在: " + name)
+	}
+	m.adding[name] = true
+	m.mu.Unlock()
+
+	client, err := NewEastMoneyClientContext(ctx, c)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.adding, name)
+	if err != nil {
+		return nil, err
+	}
+	m.clients[name] = client
+	return client, nil
+}
+
+// AddAll 同时为多个账户创建客户端并登录，key 为账户名称。
+// 登录失败的账户不会加入，登录成功的账户不受影响，返回遇到的第一个错误
+func (m *AccountManager) AddAll(ctx context.Context, accounts map[string]EastMoneyClientConfig) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for name, c := range accounts {
+		wg.Add(1)
+		go func(name string, c EastMoneyClientConfig) {
+			defer wg.Done()
+			if _, err := m.AddContext(ctx, name, c); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = errors.Wrapf(err, "账户 %s 登录失败", name)
+				}
+				mu.Unlock()
+			}
+		}(name, c)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// Get 根据名称获取账户的客户端
+func (m *AccountManager) Get(name string) (*EastMoneyClient, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	client, ok := m.clients[name]
+	return client, ok
+}
+
+// Remove 移除账户并关闭对应的客户端
+func (m *AccountManager) Remove(name string) error {
+	m.mu.Lock()
+	client, ok := m.clients[name]
+	delete(m.clients, name)
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return client.Close()
+}
+
+// Shutdown 关闭所有账户的客户端，返回遇到的第一个错误
+func (m *AccountManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = make(map[string]*EastMoneyClient)
+	m.mu.Unlock()
+
+	var firstErr error
+	for _, client := range clients {
+		if err := client.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Names 所有账户的名称，按字母顺序排列
+func (m *AccountManager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.clients))
+	for name := range m.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
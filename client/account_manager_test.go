@@ -0,0 +1,82 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+
+	"github.com/shopspring/decimal"
+)
+
+// slowRecognizer 模拟人工输入验证码，识别前等待 release 关闭
+type slowRecognizer struct {
+	code    string
+	release chan struct{}
+}
+
+func (r *slowRecognizer) Recognize(ctx context.Context, img []byte) (string, error) {
+	<-r.release
+	return r.code, nil
+}
+
+func TestAccountManager(t *testing.T) {
+	s := fakebroker.New()
+	defer s.Close()
+	s.AddAccount("a1", decimal.NewFromInt(100000))
+	s.AddAccount("a2", decimal.NewFromInt(100000))
+	s.AddAccount("a3", decimal.NewFromInt(100000))
+
+	m := client.NewAccountManager()
+	defer m.Shutdown(context.Background())
+	err := m.AddAll(context.Background(), map[string]client.EastMoneyClientConfig{
+		"first":  {Account: "a1", BaseURL: s.URL, Recognizer: fakebroker.FixedRecognizer(s.Captcha())},
+		"second": {Account: "a2", BaseURL: s.URL, Recognizer: fakebroker.FixedRecognizer(s.Captcha())},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := m.Names(); len(names) != 2 || names[0] != "first" || names[1] != "second" {
+		t.Fatalf("账户名称错误: %v", names)
+	}
+
+	// 登录期间其他账户不受影响，同名的账户不能重复添加
+	slow := &slowRecognizer{code: s.Captcha(), release: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Add("third", client.EastMoneyClientConfig{Account: "a3", BaseURL: s.URL, Recognizer: slow})
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	got := make(chan bool, 1)
+	go func() {
+		_, ok := m.Get("first")
+		got <- ok
+	}()
+	select {
+	case ok := <-got:
+		if !ok {
+			t.Fatal("没有找到账户 first")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("登录期间 Get 被阻塞")
+	}
+	if _, err := m.Add("third", client.EastMoneyClientConfig{Account: "a3", BaseURL: s.URL}); err == nil {
+		t.Fatal("正在登录的账户不能重复添加")
+	}
+	close(slow.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Get("third"); !ok {
+		t.Fatal("没有找到账户 third")
+	}
+	if err := m.Remove("second"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Get("second"); ok {
+		t.Fatal("账户 second 没有移除")
+	}
+}
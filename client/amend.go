@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+	"github.com/yfjiang-danny/eastmoneyapi/risk"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	logrus "github.com/sirupsen/logrus"
+)
+
+const (
+	// 撤单后等待委托结束的最长时间
+	amendConfirmTimeout = 10 * time.Second
+	// 等待撤单结果时查询当日委托的间隔
+	amendPollInterval = 500 * time.Millisecond
+)
+
+// AmendOrder 改单：撤销原委托，确认撤单完成和已成交的数量后，以新的价格提交剩余的数量，返回新的委托编号。
+// newPrice 为零时使用原委托的价格；newAmount 为改单后的委托总数量（包含已成交的部分），为0时使用原委托的数量，
+// 实际提交的是 newAmount 减去已成交的数量，买入时按申报规则向下取整（见 risk.BuyableLot，每手的数量为 Risk.LotSize）。
+// 原委托在撤单前已经全部成交时返回 ErrOrderFilled，已撤或者废单时返回 ErrOrderNotRevocable；
+// 原委托已经撤单但是没有剩余数量或者提交新的委托失败时返回 *AmendError
+func (e *EastMoneyClient) AmendOrder(orderId string, newPrice decimal.Decimal, newAmount int) (string, error) {
+	return e.AmendOrderContext(context.Background(), orderId, newPrice, newAmount)
+}
+
+// AmendOrderContext 改单
+func (e *EastMoneyClient) AmendOrderContext(ctx context.Context, orderId string, newPrice decimal.Decimal, newAmount int) (string, error) {
+	if newPrice.IsNegative() || newAmount < 0 {
+		return "", errors.New("改单的价格和数量不能为负数")
+	}
+	list, err := e.GetRevokeListContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	var origin *model.Order
+	for _, o := range list {
+		if o.OrderId == orderId {
+			origin = o
+			break
+		}
+	}
+	if origin == nil {
+		// 不在可撤单列表中，查询当日委托区分是已经成交还是已经撤销
+		o, err := e.findOrder(ctx, orderId)
+		if err != nil {
+			return "", err
+		}
+		if o != nil && o.OrderStatus() == model.OrderStatusFilled {
+			return "", errors.Wrapf(ErrOrderFilled, "委托 %s", orderId)
+		}
+		return "", errors.Wrapf(ErrOrderNotRevocable, "委托 %s", orderId)
+	}
+
+	results, err := e.RevokeOrdersDetailedContext(ctx, []*model.Order{origin})
+	if err != nil {
+		return "", err
+	}
+	if !results[0].Success {
+		// 撤单失败通常是因为委托在此期间已经全部成交
+		if o, err := e.findOrder(ctx, orderId); err == nil && o != nil && o.OrderStatus() == model.OrderStatusFilled {
+			return "", errors.Wrapf(ErrOrderFilled, "委托 %s", orderId)
+		}
+		return "", errors.Errorf("撤销委托 %s 失败: %s", orderId, results[0].Message)
+	}
+
+	final, err := e.waitOrderFinal(ctx, orderId)
+	if err != nil {
+		return "", err
+	}
+	filled := final.ClosingAmount()
+	switch final.OrderStatus() {
+	case model.OrderStatusFilled:
+		return "", errors.Wrapf(ErrOrderFilled, "委托 %s", orderId)
+	case model.OrderStatusRejected:
+		return "", errors.Wrapf(ErrOrderNotRevocable, "委托 %s 为废单", orderId)
+	}
+
+	if newPrice.IsZero() {
+		newPrice = origin.OrderPrice()
+	}
+	if newAmount == 0 {
+		newAmount = origin.Amount()
+	}
+	remain := newAmount - filled
+	if origin.TradeType() == model.TradeTypeBuy {
+		// 部分成交后剩余的零股（科创板不足200股）不能买入
+		remain = risk.BuyableLot(origin.Code, remain, e.config.Risk.LotSize)
+	}
+	if remain <= 0 {
+		return "", &AmendError{
+			OrderId:      orderId,
+			FilledAmount: filled,
+			Err:          errors.Errorf("改单后的数量 %d 减去已成交的数量后不符合申报数量", newAmount),
+		}
+	}
+	logrus.Infof("改单: 委托 %s 已撤单，已成交 %d，以 %s 重新委托 %d", orderId, filled, newPrice.String(), remain)
+	newId, err := e.SubmitTradeContext(ctx, model.TradeOrderForm{
+		Code:      origin.Code,
+		Name:      origin.Name,
+		Price:     newPrice,
+		Amount:    remain,
+		TradeType: origin.TradeType(),
+		// 同一个委托只会改单一次，结果未知时重试不会重复委托
+		ClientOrderId: "amend-" + orderId,
+	})
+	if err != nil {
+		return "", &AmendError{OrderId: orderId, FilledAmount: filled, Err: err}
+	}
+	return newId, nil
+}
+
+// waitOrderFinal 查询当日委托，直到委托结束（已成、已撤、部撤或者废单），超过 amendConfirmTimeout 时返回 ErrOrderStatusUnknown
+func (e *EastMoneyClient) waitOrderFinal(ctx context.Context, orderId string) (*model.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, amendConfirmTimeout)
+	defer cancel()
+	for {
+		o, err := e.findOrder(ctx, orderId)
+		if err != nil && ctx.Err() == nil {
+			return nil, err
+		}
+		if o != nil && o.OrderStatus().IsFinal() {
+			return o, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ErrOrderStatusUnknown, "等待委托 %s 撤单超时", orderId)
+		case <-time.After(amendPollInterval):
+		}
+	}
+}
+
+// findOrder 在当日委托中查找委托，没有找到时返回 nil
+func (e *EastMoneyClient) findOrder(ctx context.Context, orderId string) (*model.Order, error) {
+	orders, err := e.GetOrdersListContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		if o.OrderId == orderId {
+			return o, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,124 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func TestAmendRoundsBuyRemainder(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+	orderId, err := e.SubmitTrade(buyForm(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fill(orderId, 337, decimal.RequireFromString("3.856")); err != nil {
+		t.Fatal(err)
+	}
+	newId, err := e.AmendOrder(orderId, decimal.RequireFromString("3.850"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := findOrder(t, e, newId)
+	if o == nil || o.Amount() != 600 || !o.OrderPrice().Equal(decimal.RequireFromString("3.850")) {
+		t.Fatalf("剩余663股应该以新价格委托600股: %+v", o)
+	}
+	if o := findOrder(t, e, orderId); o.OrderStatus() != model.OrderStatusPartFillRevoked {
+		t.Fatalf("原委托应该为部撤，实际为 %s", o.Status)
+	}
+}
+
+func TestAmendOddLotRemainder(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+	orderId, err := e.SubmitTrade(buyForm(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fill(orderId, 150, decimal.RequireFromString("3.856")); err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.AmendOrder(orderId, decimal.Zero, 0)
+	var amendErr *client.AmendError
+	if !errors.As(err, &amendErr) || amendErr.OrderId != orderId || amendErr.FilledAmount != 150 {
+		t.Fatalf("剩余不足一手应该返回 AmendError，实际为 %v", err)
+	}
+	orders, err := e.GetOrdersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("不应该提交新的委托: %+v", orders)
+	}
+}
+
+func TestAmendSTARRemainder(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+	star := func(amount int) model.TradeOrderForm {
+		return model.TradeOrderForm{Code: "688981", Name: "中芯国际", Price: decimal.RequireFromString("50.00"), Amount: amount, TradeType: model.TradeTypeBuy}
+	}
+	// 科创板不小于200股时以1股递增，剩余263股全部委托
+	orderId, err := e.SubmitTrade(star(500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fill(orderId, 237, decimal.RequireFromString("50.00")); err != nil {
+		t.Fatal(err)
+	}
+	newId, err := e.AmendOrder(orderId, decimal.RequireFromString("49.98"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := findOrder(t, e, newId); o == nil || o.Amount() != 263 {
+		t.Fatalf("剩余263股应该全部委托: %+v", o)
+	}
+
+	// 剩余不足200股时不能买入
+	orderId, err = e.SubmitTrade(star(500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fill(orderId, 350, decimal.RequireFromString("50.00")); err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.AmendOrder(orderId, decimal.Zero, 0)
+	var amendErr *client.AmendError
+	if !errors.As(err, &amendErr) || amendErr.FilledAmount != 350 {
+		t.Fatalf("科创板剩余不足200股应该返回 AmendError，实际为 %v", err)
+	}
+}
+
+func TestAmendReplacementFailed(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{SubmitLookupInterval: 10 * time.Millisecond})
+	orderId, err := e.SubmitTrade(buyForm(500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fill(orderId, 100, decimal.RequireFromString("3.856")); err != nil {
+		t.Fatal(err)
+	}
+	s.DropSubmits(1, false)
+	_, err = e.AmendOrder(orderId, decimal.RequireFromString("3.850"), 0)
+	var amendErr *client.AmendError
+	if !errors.As(err, &amendErr) || amendErr.FilledAmount != 100 {
+		t.Fatalf("提交新的委托失败时应该返回 AmendError，实际为 %v", err)
+	}
+	if !errors.Is(err, client.ErrOrderStatusUnknown) {
+		t.Fatalf("应该保留提交失败的原因，实际为 %v", err)
+	}
+	// 使用改单的客户端委托编号重新提交
+	form := buyForm(400)
+	form.Price = decimal.RequireFromString("3.850")
+	form.ClientOrderId = "amend-" + orderId
+	newId, err := e.SubmitTrade(form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := findOrder(t, e, newId); o == nil || o.Amount() != 400 {
+		t.Fatalf("重新提交的委托错误: %+v", o)
+	}
+}
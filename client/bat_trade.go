@@ -1,18 +1,20 @@
 package client
 
 import (
-	"strings"
+	"context"
 
+	em_errors "github.com/yfjiang-danny/eastmoneyapi/errors"
 	"github.com/yfjiang-danny/eastmoneyapi/model"
 )
 
 // GetCanBuyNewStockList 查询可申请新股列表
 func (e *EastMoneyClient) GetCanBuyNewStockList() (*model.StockList, error) {
-	req, _ := createRequestWithBaseHeader(
-		"POST",
-		baseUrl+"/Trade/GetCanBuyNewStockListV3?validatekey="+e.validateKey,
-		nil)
-	resp, err := e.cli.Do(req)
+	return e.GetCanBuyNewStockListContext(context.Background())
+}
+
+// GetCanBuyNewStockListContext 查询可申请新股列表
+func (e *EastMoneyClient) GetCanBuyNewStockListContext(ctx context.Context) (*model.StockList, error) {
+	resp, err := e.postForm(ctx, "/Trade/GetCanBuyNewStockListV3", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -25,11 +27,12 @@ func (e *EastMoneyClient) GetCanBuyNewStockList() (*model.StockList, error) {
 
 // GetConvertibleBondList 查询新债列表
 func (e *EastMoneyClient) GetNewConvertibleBondList() (*model.ConvertibleBondList, error) {
-	req, _ := createRequestWithBaseHeader(
-		"POST",
-		baseUrl+"/Trade/GetConvertibleBondListV2?validatekey="+e.validateKey,
-		nil)
-	resp, err := e.cli.Do(req)
+	return e.GetNewConvertibleBondListContext(context.Background())
+}
+
+// GetNewConvertibleBondListContext 查询新债列表
+func (e *EastMoneyClient) GetNewConvertibleBondListContext(ctx context.Context) (*model.ConvertibleBondList, error) {
+	resp, err := e.postForm(ctx, "/Trade/GetConvertibleBondListV2", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -37,20 +40,24 @@ func (e *EastMoneyClient) GetNewConvertibleBondList() (*model.ConvertibleBondLis
 	if err := bindJson(resp.Body, &result); err != nil {
 		return nil, err
 	}
+	if err := em_errors.Parse(result.Status, result.Errcode, result.Message); err != nil {
+		return nil, err
+	}
 	return &result, nil
 }
 
 // SubmitBatTrade 申购
 func (e *EastMoneyClient) SubmitBatTrade(params model.SubmitBatTradeParams) (*model.SubmitBatTradeResult, error) {
+	return e.SubmitBatTradeContext(context.Background(), params)
+}
+
+// SubmitBatTradeContext 申购
+func (e *EastMoneyClient) SubmitBatTradeContext(ctx context.Context, params model.SubmitBatTradeParams) (*model.SubmitBatTradeResult, error) {
 	body, err := params.ToJson()
 	if err != nil {
 		return nil, err
 	}
-	req, _ := createRequestWithJson(
-		"POST",
-		baseUrl+"/Trade/SubmitBatTradeV2?validatekey="+e.validateKey,
-		strings.NewReader(string(body)))
-	resp, err := e.cli.Do(req)
+	resp, err := e.postJson(ctx, "/Trade/SubmitBatTradeV2", body)
 	if err != nil {
 		return nil, err
 	}
@@ -58,5 +65,8 @@ func (e *EastMoneyClient) SubmitBatTrade(params model.SubmitBatTradeParams) (*mo
 	if err := bindJson(resp.Body, &result); err != nil {
 		return nil, err
 	}
+	if err := em_errors.Parse(result.Status, 0, result.Message); err != nil {
+		return nil, err
+	}
 	return &result, nil
 }
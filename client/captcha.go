@@ -0,0 +1,186 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/util"
+
+	"github.com/pkg/errors"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// CaptchaRecognizer 验证码识别器，传入验证码图片，返回识别出的验证码
+type CaptchaRecognizer interface {
+	Recognize(ctx context.Context, img []byte) (string, error)
+}
+
+// HTTPRecognizer 通过 HTTP OCR 服务识别验证码，图片以 multipart 表单上传到 Host + "/ocr/file"
+type HTTPRecognizer struct {
+	Host   string
+	Client *http.Client
+}
+
+func (h *HTTPRecognizer) Recognize(ctx context.Context, img []byte) (string, error) {
+	requestBody := &bytes.Buffer{}
+	writer := multipart.NewWriter(requestBody)
+	part, err := writer.CreateFormFile("image", "verify_image")
+	if err != nil {
+		return "", errors.New("Failed to create form file: " + err.Error())
+	}
+	if _, err := part.Write(img); err != nil {
+		return "", errors.New("Failed to copy file data: " + err.Error())
+	}
+	if err := writer.Close(); err != nil {
+		return "", errors.New("Failed to close writer: " + err.Error())
+	}
+
+	apiURL := fmt.Sprintf("%s/ocr/file", h.Host)
+	request, err := http.NewRequestWithContext(ctx, "POST", apiURL, requestBody)
+	if err != nil {
+		return "", errors.New("Failed to create request: " + err.Error())
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	cli := h.Client
+	if cli == nil {
+		cli = &http.Client{Timeout: 3 * time.Second}
+	}
+	response, err := cli.Do(request)
+	if err != nil {
+		return "", errors.New("Failed to send request: " + err.Error())
+	}
+	defer response.Body.Close()
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", errors.New("Failed to read response body: " + err.Error())
+	}
+	return string(responseBytes), nil
+}
+
+// DdddOcrRecognizer 调用本地的 python ddddocr 脚本（util/ocr.py）识别验证码
+type DdddOcrRecognizer struct{}
+
+func (d *DdddOcrRecognizer) Recognize(ctx context.Context, img []byte) (string, error) {
+	f, err := ioutil.TempFile("", "eastmoney_yzm_*.png")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(img); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	code, err := util.ImgOCRContext(ctx, f.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(code), nil
+}
+
+// ManualRecognizer 人工识别验证码
+// 验证码图片写入 ImagePath，如果设置了 CodeFile，则等待该文件出现并读取其中的验证码，
+// 否则从 Input（默认为标准输入）读取一行作为验证码。
+// 读取 Input 无法被取消，第一次读取时会启动一个协程持续按行读取，直到 Input 结束（标准输入会一直存在到进程退出），
+// 同一个识别器的多次识别共用这个协程，不会丢失已经缓冲的输入。
+// 识别被 ctx 取消后才输入的一行会被下一次识别读取
+type ManualRecognizer struct {
+	ImagePath string
+	CodeFile  string
+	Input     io.Reader
+	// 等待 CodeFile 的超时时间，默认5分钟
+	Timeout time.Duration
+
+	once  sync.Once
+	lines chan inputLine
+}
+
+type inputLine struct {
+	line string
+	err  error
+}
+
+// readLines 在协程中按行读取 Input，读取到 EOF 或者出错后结束
+func (m *ManualRecognizer) readLines() {
+	input := m.Input
+	if input == nil {
+		input = os.Stdin
+	}
+	m.lines = make(chan inputLine, 1)
+	go func() {
+		reader := bufio.NewReader(input)
+		for {
+			line, err := reader.ReadString('\n')
+			m.lines <- inputLine{line, err}
+			if err != nil {
+				close(m.lines)
+				return
+			}
+		}
+	}()
+}
+
+func (m *ManualRecognizer) Recognize(ctx context.Context, img []byte) (string, error) {
+	imagePath := m.ImagePath
+	if imagePath == "" {
+		imagePath = filepath.Join(os.TempDir(), "eastmoney_yzm.png")
+	}
+	if err := ioutil.WriteFile(imagePath, img, 0600); err != nil {
+		return "", err
+	}
+
+	if m.CodeFile == "" {
+		m.once.Do(m.readLines)
+		logrus.Infof("验证码图片已保存到 %s，请输入验证码：", imagePath)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case r, ok := <-m.lines:
+			if !ok {
+				return "", io.EOF
+			}
+			if r.err != nil && (r.err != io.EOF || r.line == "") {
+				return "", r.err
+			}
+			return strings.TrimSpace(r.line), nil
+		}
+	}
+
+	logrus.Infof("验证码图片已保存到 %s，请将验证码写入 %s", imagePath, m.CodeFile)
+	timeout := m.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		data, err := ioutil.ReadFile(m.CodeFile)
+		if err == nil {
+			os.Remove(m.CodeFile)
+			return strings.TrimSpace(string(data)), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	return "", errors.New("等待人工输入验证码超时")
+}
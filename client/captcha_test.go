@@ -0,0 +1,122 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// recordingRecognizer 记录收到的验证码图片，返回固定的验证码
+type recordingRecognizer struct {
+	code   string
+	images [][]byte
+}
+
+func (r *recordingRecognizer) Recognize(ctx context.Context, img []byte) (string, error) {
+	r.images = append(r.images, img)
+	return r.code, nil
+}
+
+func TestLoginUsesRecognizer(t *testing.T) {
+	s := fakebroker.New()
+	defer s.Close()
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	r := &recordingRecognizer{code: s.Captcha()}
+	e, err := client.NewEastMoneyClient(client.EastMoneyClientConfig{Account: testAccount, BaseURL: s.URL, Recognizer: r})
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	defer e.Close()
+	if len(r.images) != 1 || len(r.images[0]) == 0 {
+		t.Fatalf("识别器应该收到一张验证码图片，实际 %d 张", len(r.images))
+	}
+}
+
+func TestManualRecognizerInput(t *testing.T) {
+	dir := t.TempDir()
+	m := &client.ManualRecognizer{
+		ImagePath: filepath.Join(dir, "yzm.png"),
+		Input:     strings.NewReader(" 1234 \n5678\n"),
+	}
+	for _, want := range []string{"1234", "5678"} {
+		code, err := m.Recognize(context.Background(), []byte("png"))
+		if err != nil || code != want {
+			t.Fatalf("应该读取到 %s，实际为 %q %v", want, code, err)
+		}
+	}
+	if data, err := ioutil.ReadFile(m.ImagePath); err != nil || string(data) != "png" {
+		t.Fatalf("验证码图片没有写入 ImagePath: %q %v", data, err)
+	}
+	if _, err := m.Recognize(context.Background(), []byte("png")); err != io.EOF {
+		t.Fatalf("输入结束后应该返回 io.EOF，实际为 %v", err)
+	}
+}
+
+func TestManualRecognizerCancel(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	m := &client.ManualRecognizer{ImagePath: filepath.Join(t.TempDir(), "yzm.png"), Input: r}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := m.Recognize(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("应该返回 ctx 的错误，实际为 %v", err)
+	}
+	// 取消之后输入的一行由下一次识别读取
+	go w.Write([]byte("4321\n"))
+	code, err := m.Recognize(context.Background(), nil)
+	if err != nil || code != "4321" {
+		t.Fatalf("应该读取到 4321，实际为 %q %v", code, err)
+	}
+}
+
+func TestManualRecognizerCodeFile(t *testing.T) {
+	dir := t.TempDir()
+	codeFile := filepath.Join(dir, "code.txt")
+	m := &client.ManualRecognizer{ImagePath: filepath.Join(dir, "yzm.png"), CodeFile: codeFile}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ioutil.WriteFile(codeFile, []byte("8765\n"), 0600)
+	}()
+	code, err := m.Recognize(context.Background(), nil)
+	if err != nil || code != "8765" {
+		t.Fatalf("应该读取到 8765，实际为 %q %v", code, err)
+	}
+	if _, err := os.Stat(codeFile); !os.IsNotExist(err) {
+		t.Fatal("读取后应该删除 CodeFile")
+	}
+}
+
+func TestHTTPRecognizer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ocr/file" {
+			http.NotFound(w, r)
+			return
+		}
+		f, _, err := r.FormFile("image")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := ioutil.ReadAll(f)
+		w.Write([]byte(strings.ToUpper(string(data))))
+	}))
+	defer ts.Close()
+	h := &client.HTTPRecognizer{Host: ts.URL}
+	code, err := h.Recognize(context.Background(), []byte("abcd"))
+	if err != nil || code != "ABCD" {
+		t.Fatalf("应该返回 OCR 服务的结果，实际为 %q %v", code, err)
+	}
+}
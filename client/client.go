@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -13,7 +14,6 @@ import (
 	"io/ioutil"
 	"log"
 	math_rand "math/rand"
-	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -22,7 +22,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/yfjiang-danny/eastmoneyapi/captcha"
 	"github.com/yfjiang-danny/eastmoneyapi/model"
+	"github.com/yfjiang-danny/eastmoneyapi/risk"
 	"github.com/yfjiang-danny/eastmoneyapi/util"
 
 	"github.com/PuerkitoBio/goquery"
@@ -32,16 +34,35 @@ import (
 )
 
 // 东财网页版
-var baseUrl = "https://jywg.18.cn"
+const defaultBaseUrl = "https://jywg.18.cn"
 
-var client *EastMoneyClient
-var initClientOnce sync.Once
+// 定时重新登录的间隔
+const reloginInterval = 10 * time.Minute
 
 type EastMoneyClient struct {
-	cli         *http.Client
-	config      EastMoneyClientConfig
-	closeCh     chan struct{}
+	cli     *http.Client
+	config  EastMoneyClientConfig
+	baseUrl string
+
+	// validateKey 每次登录后都会变化，generation 记录登录的次数，用于判断会话失效后是否已经重新登录过
+	sessionMu   sync.RWMutex
 	validateKey string
+	generation  uint64
+	// 保证同一时间只有一个登录流程
+	loginMu sync.Mutex
+
+	// 关闭客户端时取消重新登录的协程，loopDone 在协程退出后关闭
+	cancelLoop context.CancelFunc
+	loopDone   chan struct{}
+
+	// closed 之后不再接受新的请求，inflight 记录正在进行的请求
+	mu       sync.RWMutex
+	closed   bool
+	inflight sync.WaitGroup
+
+	risk *risk.Checker
+	// 客户端委托编号与委托编号的对应关系
+	clientOrders *clientOrders
 }
 
 type EastMoneyClientConfig struct {
@@ -49,60 +70,182 @@ type EastMoneyClientConfig struct {
 	Account  string
 	Password string
 
-	// ocr host
+	// 东财网页版地址，默认为 https://jywg.18.cn，测试时可以指向本地的模拟服务
+	BaseURL string
+
+	// ocr host，设置后通过 HTTP OCR 服务识别验证码
 	OCRHost string
+
+	// 验证码模板文件（captcha.Solver.Save 生成），设置后使用纯Go识别验证码，不再依赖 OCR 服务
+	CaptchaTemplates string
+
+	// 验证码识别器，优先级最高；为空时依次使用 CaptchaTemplates、OCRHost 对应的 HTTP 识别服务，都没有配置时返回 ErrNoRecognizer
+	Recognizer CaptchaRecognizer `mapstructure:"-"`
+
+	// 关闭客户端时是否调用东财的退出登录接口
+	LogoutOnClose bool
+
+	// 会话保存的文件，设置后登录成功会保存 cookie 和 validatekey，启动时优先复用；
+	// SessionKey 不为空时加密保存
+	SessionFile string
+	SessionKey  string
+	// 自定义的会话存储，优先级高于 SessionFile
+	SessionStore SessionStore `mapstructure:"-"`
+
+	// 查询接口每页的数量，默认100
+	PageSize int
+	// 历史查询的最大日期跨度（天），默认30天，超过时自动拆分为多次查询
+	HistoryMaxDays int
+
+	// 提交委托前是否检查最大可买/可卖数量，见 MaxQuantityPolicy，默认不检查
+	MaxQuantityPolicy MaxQuantityPolicy
+
+	// 提交委托前的风控检查，默认不检查
+	Risk risk.Config
+
+	// 提交委托的结果未知且当日委托中没有找到该委托时，重新提交的次数，默认为0（不重新提交）
+	SubmitRetries int
+	// 提交委托的结果未知时在当日委托中查找的次数和间隔，默认查找3次，间隔500毫秒。
+	// 券商的委托列表可能稍晚才出现刚提交的委托，多次查找都没有找到才认为委托没有到达券商
+	SubmitLookups        int
+	SubmitLookupInterval time.Duration
+}
+
+// NewEastMoneyClient 创建客户端并登录，每次调用都会创建独立的客户端，拥有各自的 cookie、validatekey 和重新登录的协程
+// 登录失败时返回 *LoginError，可以通过 errors.Is 判断失败的原因；没有配置验证码识别方式时返回 ErrNoRecognizer
+func NewEastMoneyClient(c EastMoneyClientConfig) (*EastMoneyClient, error) {
+	return NewEastMoneyClientContext(context.Background(), c)
 }
 
-func NewEastMoneyClient(c EastMoneyClientConfig) *EastMoneyClient {
-	initClientOnce.Do(func() {
-		jar, _ := cookiejar.New(nil)
-		client = &EastMoneyClient{
-			cli: &http.Client{
-				Timeout: 3 * time.Second,
-				Jar:     jar,
-			},
-			config:  c,
-			closeCh: make(chan struct{}, 1),
+// NewEastMoneyClientContext 与 NewEastMoneyClient 相同，ctx 用于取消首次登录（包括登录重试）
+func NewEastMoneyClientContext(ctx context.Context, c EastMoneyClientConfig) (*EastMoneyClient, error) {
+	jar, _ := cookiejar.New(nil)
+	client := &EastMoneyClient{
+		cli: &http.Client{
+			Timeout: 3 * time.Second,
+			Jar:     jar,
+		},
+		config:       c,
+		baseUrl:      strings.TrimSuffix(c.BaseURL, "/"),
+		loopDone:     make(chan struct{}),
+		clientOrders: newClientOrders(),
+	}
+	if client.baseUrl == "" {
+		client.baseUrl = defaultBaseUrl
+	}
+	client.risk = risk.NewChecker(c.Risk, client.findPosition)
+	if c.Recognizer == nil && c.CaptchaTemplates != "" {
+		solver, err := captcha.LoadFile(c.CaptchaTemplates)
+		if err != nil {
+			return nil, errors.Wrapf(err, "加载验证码模板 %s 失败", c.CaptchaTemplates)
 		}
-		if err := client.login(); err != nil {
-			// 第一次登录失败，说明账号密码可能是错误的，直接panic
-			panic("账号登录失败," + err.Error())
+		client.config.Recognizer = solver
+	}
+	if client.config.Recognizer == nil && c.OCRHost == "" {
+		return nil, ErrNoRecognizer
+	}
+	if !client.restoreSession(ctx) {
+		if err := client.login(ctx); err != nil {
+			return nil, err
 		}
-		go func() {
-			for {
-				time.Sleep(time.Minute * 10)
-				select {
-				case <-client.closeCh:
-					return
-				default:
-					client.login()
-				}
+	}
+	var loopCtx context.Context
+	loopCtx, client.cancelLoop = context.WithCancel(context.Background())
+	go client.reloginLoop(loopCtx)
+
+	return client, nil
+}
+
+// reloginLoop 定时重新登录，保持会话有效
+func (e *EastMoneyClient) reloginLoop(ctx context.Context) {
+	defer close(e.loopDone)
+	ticker := time.NewTicker(reloginInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.login(ctx); err != nil && ctx.Err() == nil {
+				logrus.Errorf("账号 %s 重新登录失败: %s", e.config.Account, err.Error())
 			}
-		}()
-	})
+		}
+	}
+}
+
+// Close 关闭客户端，等同于 Shutdown(context.Background())
+func (e *EastMoneyClient) Close() error {
+	return e.Shutdown(context.Background())
+}
 
-	return client
+// Shutdown 关闭客户端：立即停止重新登录的协程，不再接受新的请求，等待正在进行的请求完成。
+// 如果配置了 LogoutOnClose，最后调用东财的退出登录接口。ctx 超时后直接返回 ctx.Err()
+func (e *EastMoneyClient) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.mu.Unlock()
+
+	e.cancelLoop()
+	done := make(chan struct{})
+	go func() {
+		e.inflight.Wait()
+		<-e.loopDone
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+	}
+
+	if e.config.LogoutOnClose {
+		return e.logout(ctx)
+	}
+	return nil
+}
+
+// logout 退出登录
+func (e *EastMoneyClient) logout(ctx context.Context) error {
+	req, _ := createRequestWithBaseHeader(ctx, "GET", e.baseUrl+"/Login/ExitLogin", nil)
+	resp, err := e.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	e.clearSession()
+	return nil
 }
 
 // login 登录接口
-func (e *EastMoneyClient) login() error {
+func (e *EastMoneyClient) login(ctx context.Context) error {
+	e.loginMu.Lock()
+	defer e.loginMu.Unlock()
+	return e.loginLocked(ctx)
+}
+
+// loginLocked 登录，调用方需要持有 loginMu
+func (e *EastMoneyClient) loginLocked(ctx context.Context) error {
 	var loginFn = func() error {
 		randNumber := decimal.NewFromFloat(math_rand.Float64())
-		verifyCode, err := e.getVerifyCode(randNumber.String())
+		verifyCode, err := e.getVerifyCode(ctx, randNumber.String())
 		if err != nil {
-			return errors.New("获取验证码失败: " + err.Error())
+			return err
 		}
 
 		// 东方财富的验证码全是数字，如果识别出字母说明出错,不需要再往下执行了
 		if _, err := strconv.Atoi(verifyCode); err != nil || len(verifyCode) != 4 {
-			return errors.New("验证码识别出错")
+			return newLoginError(ErrCaptcha, errors.New("验证码识别出错: "+verifyCode))
 		}
 
 		// secInfo, err := e.getSecurityInfo(verifyCode)
 		// if err != nil {
 		// 	return errors.New("验证码安全加密识别失败: " + err.Error())
 		// }
-		return e.doLogin(loginReq{
+		return e.doLogin(ctx, loginReq{
 			UserId:     e.config.Account,
 			Password:   e.config.Password,
 			VerifyCode: verifyCode,
@@ -110,8 +253,15 @@ func (e *EastMoneyClient) login() error {
 			// SecurityInfo: secInfo,
 		})
 	}
-	return util.Retry(5, loginFn)
-
+	// 账号密码错误时不再重试，以免账号被锁定
+	err := util.RetryIf(ctx, 5, loginFn, func(err error) bool {
+		return !errors.Is(err, ErrBadCredentials)
+	})
+	if err != nil {
+		return err
+	}
+	e.saveSession()
+	return nil
 }
 
 type loginReq struct {
@@ -122,7 +272,7 @@ type loginReq struct {
 	SecurityInfo string
 }
 
-func (e *EastMoneyClient) doLogin(param loginReq) error {
+func (e *EastMoneyClient) doLogin(ctx context.Context, param loginReq) error {
 	var formData = make(url.Values, 0)
 	formData.Add("userId", param.UserId)
 	formData.Add("randNumber", param.RandNumber)
@@ -134,108 +284,197 @@ func (e *EastMoneyClient) doLogin(param loginReq) error {
 	formData.Add("authCode", "")
 
 	body := strings.NewReader(formData.Encode())
-	req, _ := createRequestWithBaseHeader("POST", baseUrl+"/Login/Authentication?validatekey=", body)
+	req, _ := createRequestWithBaseHeader(ctx, "POST", e.baseUrl+"/Login/Authentication?validatekey=", body)
 
 	resp, err := e.cli.Do(req)
 	if err != nil {
-		return errors.New(err.Error())
+		return newLoginError(ErrNetwork, err)
 	}
-	var result = struct {
-		Status  interface{} `json:"Status"`
-		ErrCode interface{} `json:"Errcode"`
-		Message string      `json:"Message"`
-	}{}
-
+	var result model.Response
 	if err := bindJson(resp.Body, &result); err != nil {
-		return err
+		return newLoginError(ErrPageStructure, err)
 	}
-	if s, ok := result.Status.(float64); !ok || s != 0 {
-		return errors.New(result.Message)
+	if err := result.Err(); err != nil {
+		return newLoginError(classifyLoginMessage(result.Message), err)
 	}
 
-	return e.getValidateKey()
+	return e.getValidateKey(ctx)
+}
+
+// classifyLoginMessage 根据登录接口返回的消息判断失败原因
+func classifyLoginMessage(msg string) error {
+	switch {
+	case strings.Contains(msg, "验证码"):
+		return ErrCaptcha
+	case strings.Contains(msg, "密码"), strings.Contains(msg, "账号"), strings.Contains(msg, "锁定"):
+		return ErrBadCredentials
+	default:
+		return ErrLoginRejected
+	}
 }
 
 // 这个ValidateKey隐藏在html中，随机访问一个页面，解析出来即可
-func (e *EastMoneyClient) getValidateKey() error {
-	req, _ := createRequestWithBaseHeader("GET", baseUrl+"/Search/Position", nil)
+func (e *EastMoneyClient) getValidateKey(ctx context.Context) error {
+	req, _ := createRequestWithBaseHeader(ctx, "GET", e.baseUrl+"/Search/Position", nil)
 	resp, err := e.cli.Do(req)
 	if err != nil {
-		return errors.New(err.Error())
+		return newLoginError(ErrNetwork, err)
 	}
 	defer resp.Body.Close()
 	// Load the HTML document
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		logrus.Fatal(err)
+		return newLoginError(ErrPageStructure, err)
 	}
 	target := doc.Find("#em_validatekey")
 	if len(target.Nodes) != 1 {
-		return errors.New("无法找到目标节点")
+		return newLoginError(ErrPageStructure, errors.New("无法找到目标节点"))
 	}
 	attrs := target.Nodes[0].Attr
 	for i := range attrs {
 		if attrs[i].Key == "value" {
-			e.validateKey = attrs[i].Val
+			e.setValidateKey(attrs[i].Val)
 			return nil
 		}
 	}
-	return errors.New("目标节点，没有value属性")
+	return newLoginError(ErrPageStructure, errors.New("目标节点，没有value属性"))
 }
 
 // SubmitTrade 提交订单交易
 func (e *EastMoneyClient) SubmitTrade(order model.TradeOrderForm) (string, error) {
+	return e.SubmitTradeContext(context.Background(), order)
+}
+
+// SubmitTradeContext 提交订单交易。
+// 每个委托都有客户端委托编号（order.ClientOrderId，为空时自动生成），提交成功后与委托编号关联。
+// 请求超时等无法确定委托是否到达券商的情况下，会先在当日委托中查找（见 SubmitLookups）相同代码、方向、价格、数量的委托，
+// 找到则直接返回，否则按 SubmitRetries 重新提交，仍无法确定时返回 *AmbiguousSubmitError。
+// 使用相同的 ClientOrderId 再次调用时同样会先查找，不会重复委托
+func (e *EastMoneyClient) SubmitTradeContext(ctx context.Context, order model.TradeOrderForm) (string, error) {
+	if order.ClientOrderId == "" {
+		order.ClientOrderId = NewClientOrderId()
+	}
+	// 风控、最大数量检查和委托匹配都使用实际提交的价格
+	order.Price = roundPrice(order.Code, order.Price)
+	co, err := e.clientOrders.begin(order)
+	if err != nil {
+		return "", err
+	}
+	defer e.clientOrders.end(co.ClientOrderId)
+	if co.Status == ClientOrderSubmitted {
+		return co.OrderId, nil
+	}
+	if co.Status == ClientOrderUnknown {
+		// 上一次提交的结果未知，先在当日委托中查找
+		orderId, err := e.lookupSubmittedOrder(ctx, co)
+		if err != nil {
+			return "", &AmbiguousSubmitError{ClientOrderId: co.ClientOrderId, Err: err}
+		}
+		if orderId != "" {
+			e.clientOrders.link(co.ClientOrderId, orderId)
+			return orderId, nil
+		}
+		e.risk.Release(co.Form)
+	}
+
+	order, err = e.checkMaxQuantity(ctx, order)
+	if err != nil {
+		e.clientOrders.setStatus(co.ClientOrderId, ClientOrderRejected)
+		return "", err
+	}
+	if err := e.risk.Check(ctx, order); err != nil {
+		e.clientOrders.setStatus(co.ClientOrderId, ClientOrderRejected)
+		return "", err
+	}
+	co = e.clientOrders.attempt(co.ClientOrderId, order)
+	for retry := 0; ; retry++ {
+		orderId, err := e.submitTrade(ctx, order)
+		if err == nil {
+			e.clientOrders.link(co.ClientOrderId, orderId)
+			return orderId, nil
+		}
+		if !isAmbiguousSubmitError(err) {
+			e.clientOrders.setStatus(co.ClientOrderId, ClientOrderRejected)
+			e.risk.Release(order)
+			return "", err
+		}
+		logrus.Warnf("委托 %s 提交结果未知，在当日委托中查找: %s", co.ClientOrderId, err.Error())
+		orderId, lookupErr := e.lookupSubmittedOrder(ctx, co)
+		if lookupErr == nil && orderId != "" {
+			e.clientOrders.link(co.ClientOrderId, orderId)
+			return orderId, nil
+		}
+		if lookupErr != nil || retry >= e.config.SubmitRetries {
+			e.clientOrders.setStatus(co.ClientOrderId, ClientOrderUnknown)
+			if lookupErr != nil {
+				err = errors.Wrap(lookupErr, err.Error())
+			}
+			return "", &AmbiguousSubmitError{ClientOrderId: co.ClientOrderId, Err: err}
+		}
+		logrus.Warnf("当日委托中没有找到委托 %s，重新提交", co.ClientOrderId)
+	}
+}
+
+// Risk 风控检查器，可以在运行时开启紧急停止交易
+func (e *EastMoneyClient) Risk() *risk.Checker {
+	return e.risk
+}
+
+// findPosition 查询指定证券的持仓，没有持仓时返回 nil
+func (e *EastMoneyClient) findPosition(ctx context.Context, code string) (*model.PositionDetail, error) {
+	positions, err := e.GetStockListContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range positions {
+		if p.Code == code {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// roundPrice 委托价格保留的小数位数，基金3位，股票2位
+func roundPrice(code string, price decimal.Decimal) decimal.Decimal {
+	return risk.RoundPrice(code, price)
+}
+
+func (e *EastMoneyClient) submitTrade(ctx context.Context, order model.TradeOrderForm) (string, error) {
 	var formData = make(url.Values, 0)
 	formData.Add("stockCode", order.Code)
 	formData.Add("zqmc", order.Name)
 	formData.Add("amount", strconv.Itoa(order.Amount))
 	formData.Add("tradeType", string(order.TradeType))
 	formData.Add("market", util.GetMarket(order.Code))
-	if util.IsEFT(order.Code) {
-		order.Price = order.Price.Round(3)
-	} else {
-		order.Price = order.Price.Round(2)
-	}
+	order.Price = roundPrice(order.Code, order.Price)
 	formData.Add("price", order.Price.String())
 
-	req, _ := createRequestWithBaseHeader(
-		"POST",
-		baseUrl+"/Trade/SubmitTradeV2?validatekey="+e.validateKey,
-		strings.NewReader(formData.Encode()),
-	)
-	resp, err := e.cli.Do(req)
+	resp, err := e.postForm(ctx, "/Trade/SubmitTradeV2", formData)
 	if err != nil {
-		return "", errors.New(err.Error())
+		return "", err
 	}
 
-	defer resp.Body.Close()
-	var decoder = json.NewDecoder(resp.Body)
-	var result = struct {
-		Status  int    `json:"status"`
-		Message string `json:"Message"`
-		Data    []struct {
-			OrderId string `json:"Wtbh"`
-		} `json:"Data"`
-	}{}
-	if err := decoder.Decode(&result); err != nil {
-		return "", errors.New(err.Error())
+	var data []struct {
+		OrderId string `json:"Wtbh"`
 	}
-	if result.Status != 0 {
-		return "", errors.New(result.Message)
+	if err := bindResponse(resp, &data); err != nil {
+		return "", err
 	}
-	if len(result.Data) != 1 {
+	if len(data) != 1 {
 		return "", errors.New("未知情况发生，委托编号不是唯一")
 	}
 	msg := fmt.Sprintf(
 		"\n订单委托成功:\n"+
 			"\t委托编号: %s\n"+
+			"\t客户端委托编号: %s\n"+
 			"\t委托时间: %s\n"+
 			"\t代码: %s\n"+
 			"\t名称: %s\n"+
 			"\t委托数量: %d\n"+
 			"\t委托价格: %s\n"+
 			"\t委托方向: %s\n",
-		result.Data[0].OrderId,
+		data[0].OrderId,
+		order.ClientOrderId,
 		time.Now().Format("2006-01-02 15:04:05"),
 		order.Code,
 		order.Name,
@@ -243,47 +482,48 @@ func (e *EastMoneyClient) SubmitTrade(order model.TradeOrderForm) (string, error
 		order.Price.String(),
 		order.TradeType)
 	log.Println(msg)
-	return result.Data[0].OrderId, nil
+	return data[0].OrderId, nil
 }
 
-// GetOrdersList 获取当日的所有订单信息
+// GetOrdersList 获取当日的所有订单信息，会自动翻页直到读取全部数据
 func (e *EastMoneyClient) GetOrdersList() ([]*model.Order, error) {
-	return e.getOrders(baseUrl + "/Search/GetOrdersData?validatekey=" + e.validateKey)
+	return e.GetOrdersListContext(context.Background())
+}
+
+// GetOrdersListContext 获取当日的所有订单信息
+func (e *EastMoneyClient) GetOrdersListContext(ctx context.Context) ([]*model.Order, error) {
+	return e.OrdersIterator(0).All(ctx)
 }
 
 // GetDealList 获取当日成交信息
 func (e *EastMoneyClient) GetDealList() ([]*model.Order, error) {
-	return e.getOrders(baseUrl + "/Search/GetDealData?validatekey=" + e.validateKey)
+	return e.GetDealListContext(context.Background())
+}
+
+// GetDealListContext 获取当日成交信息
+func (e *EastMoneyClient) GetDealListContext(ctx context.Context) ([]*model.Order, error) {
+	return e.DealsIterator(0).All(ctx)
 }
 
 // GetRevokeList 获取可撤单的订单信息
 func (e *EastMoneyClient) GetRevokeList() ([]*model.Order, error) {
-	return e.getOrders(baseUrl + "/Trade/GetRevokeList?validatekey=" + e.validateKey)
+	return e.GetRevokeListContext(context.Background())
 }
 
-func (e *EastMoneyClient) getOrders(u string) ([]*model.Order, error) {
-	var form = make(url.Values, 0)
-	form.Add("qqhs", "100")
-	req, _ := createRequestWithBaseHeader("POST", u, strings.NewReader(form.Encode()))
-	resp, err := e.cli.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	var result = struct {
-		Data    []*model.Order `json:"Data"`
-		Status  int            `json:"Status"`
-		Message string         `json:"Message"`
-	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-	return result.Data, nil
+// GetRevokeListContext 获取可撤单的订单信息
+func (e *EastMoneyClient) GetRevokeListContext(ctx context.Context) ([]*model.Order, error) {
+	return e.RevokeListIterator(0).All(ctx)
 }
 
-// RevokeOrders 撤单，支持批量撤单，但是不建议使用，返回一串的字符串，需要自行判断有没有撤单成功。
+// RevokeOrders 撤单，支持批量撤单，返回一串的字符串，需要自行判断有没有撤单成功。
 // 格式为： 委托编号: 消息
+// 需要每个委托的撤单结果时使用 RevokeOrdersDetailed
 func (e *EastMoneyClient) RevokeOrders(list []*model.Order) (string, error) {
+	return e.RevokeOrdersContext(context.Background(), list)
+}
+
+// RevokeOrdersContext 撤单，返回结果与 RevokeOrders 相同
+func (e *EastMoneyClient) RevokeOrdersContext(ctx context.Context, list []*model.Order) (string, error) {
 	if len(list) == 0 {
 		return "没有需要撤单的交易", nil
 	}
@@ -296,12 +536,7 @@ func (e *EastMoneyClient) RevokeOrders(list []*model.Order) (string, error) {
 	var form = make(url.Values)
 	form.Add("revokes", revokes)
 
-	var req, _ = createRequestWithBaseHeader(
-		"Post",
-		baseUrl+"/Trade/RevokeOrders?validatekey="+e.validateKey,
-		strings.NewReader(form.Encode()),
-	)
-	resp, err := e.cli.Do(req)
+	resp, err := e.postForm(ctx, "/Trade/RevokeOrders", form)
 	if err != nil {
 		return "", err
 	}
@@ -311,54 +546,41 @@ func (e *EastMoneyClient) RevokeOrders(list []*model.Order) (string, error) {
 	return buf.String(), nil
 }
 
-// GetStockList 查询当前的持仓情况
+// GetStockList 查询当前的持仓情况，会自动翻页直到读取全部数据
 func (e *EastMoneyClient) GetStockList() ([]*model.PositionDetail, error) {
-	var formData = make(url.Values, 0)
-	formData.Add("qqhs", "10")
-	req, _ := createRequestWithBaseHeader("POST", baseUrl+"/Search/GetStockList", strings.NewReader(formData.Encode()))
-	resp, err := e.cli.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	var decoder = json.NewDecoder(resp.Body)
-	var result = struct {
-		Message string                  `json:"Message"`
-		Data    []*model.PositionDetail `json:"Data"`
-	}{}
-	if err := decoder.Decode(&result); err != nil {
-		return nil, err
-	}
+	return e.GetStockListContext(context.Background())
+}
 
-	return result.Data, err
+// GetStockListContext 查询当前的持仓情况
+func (e *EastMoneyClient) GetStockListContext(ctx context.Context) ([]*model.PositionDetail, error) {
+	return e.StockListIterator(0).All(ctx)
 }
 
 // QueryAssetAndPosition 查询账户资产和持仓情况
 func (e *EastMoneyClient) QueryAssetAndPosition() (*model.AccountDetail, error) {
+	return e.QueryAssetAndPositionContext(context.Background())
+}
+
+// QueryAssetAndPositionContext 查询账户资产和持仓情况
+func (e *EastMoneyClient) QueryAssetAndPositionContext(ctx context.Context) (*model.AccountDetail, error) {
 	var form = make(url.Values, 0)
 	form.Add("moneyType", "RMB")
-	req, _ := createRequestWithBaseHeader(
-		"Post",
-		baseUrl+"/Com/queryAssetAndPositionV1?validatekey="+e.validateKey,
-		strings.NewReader(form.Encode()))
-	resp, err := e.cli.Do(req)
+	resp, err := e.postForm(ctx, "/Com/queryAssetAndPositionV1", form)
 	if err != nil {
 		return nil, err
 	}
-	var result struct {
-		Data []model.AccountDetail `json:"Data"`
-	}
-	if err := bindJson(resp.Body, &result); err != nil {
+	var data []model.AccountDetail
+	if err := bindResponse(resp, &data); err != nil {
 		return nil, err
 	}
-	if len(result.Data) != 1 {
+	if len(data) != 1 {
 		return nil, errors.New("仅支持查询一个账户详情")
 	}
-	return &result.Data[0], nil
+	return &data[0], nil
 }
 
-func createRequestWithBaseHeader(method string, url string, body io.Reader) (*http.Request, error) {
-	request, err := http.NewRequest(method, url, body)
+func createRequestWithBaseHeader(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -368,8 +590,8 @@ func createRequestWithBaseHeader(method string, url string, body io.Reader) (*ht
 	return request, nil
 }
 
-func createRequestWithJson(method string, url string, body io.Reader) (*http.Request, error) {
-	request, err := http.NewRequest(method, url, body)
+func createRequestWithJson(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -396,46 +618,40 @@ func (e *EastMoneyClient) getSecurityInfo(code string) (string, error) {
 }
 
 // 获取验证码图片, 需要传入一个数字绑定图片
-func (e *EastMoneyClient) getVerifyCode(randNum string) (string, error) {
-	resp, err := e.cli.Get(baseUrl + "/Login/YZM?randNum=" + randNum)
+func (e *EastMoneyClient) getVerifyCode(ctx context.Context, randNum string) (string, error) {
+	req, _ := createRequestWithBaseHeader(ctx, "GET", e.baseUrl+"/Login/YZM?randNum="+randNum, nil)
+	resp, err := e.cli.Do(req)
 	if err != nil {
-		return "", errors.New(err.Error())
+		return "", newLoginError(ErrNetwork, err)
 	}
 	defer resp.Body.Close()
 
-	requestBody := &bytes.Buffer{}
-	writer := multipart.NewWriter(requestBody)
-	part, err := writer.CreateFormFile("image", "verify_image")
-	if err != nil {
-		return "", errors.New("Failed to create form file: " + err.Error())
-	}
-	_, err = io.Copy(part, resp.Body)
-	if err != nil {
-		return "", errors.New("Failed to copy file data: " + err.Error())
-	}
-	err = writer.Close()
+	img, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", errors.New("Failed to close writer: " + err.Error())
+		return "", newLoginError(ErrNetwork, errors.New("Failed to read captcha image: "+err.Error()))
 	}
-
-	apiURL := fmt.Sprintf("%s/ocr/file", e.config.OCRHost)
-	request, err := http.NewRequest("POST", apiURL, requestBody)
+	code, err := e.recognizer().Recognize(ctx, img)
 	if err != nil {
-		return "", errors.New("Failed to create request: " + err.Error())
+		return "", newLoginError(ErrCaptcha, err)
 	}
-	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return code, nil
+}
 
-	response, err := e.cli.Do(request)
-	if err != nil {
-		return "", errors.New("Failed to send request: " + err.Error())
+// recognizer 验证码识别器：Recognizer（包括 CaptchaTemplates 加载的模板）> OCRHost
+func (e *EastMoneyClient) recognizer() CaptchaRecognizer {
+	if e.config.Recognizer != nil {
+		return e.config.Recognizer
 	}
-	defer response.Body.Close()
+	return &HTTPRecognizer{Host: e.config.OCRHost, Client: e.cli}
+}
 
-	responseBytes, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return "", errors.New("Failed to read response body: " + err.Error())
+// bindResponse 解析东财接口的通用响应，接口返回错误时返回 *em_errors.BrokerError
+func bindResponse(resp *http.Response, data interface{}) error {
+	var result model.Response
+	if err := bindJson(resp.Body, &result); err != nil {
+		return err
 	}
-	return string(responseBytes), nil
+	return result.Bind(data)
 }
 
 func bindJson(r io.ReadCloser, t interface{}) error {
@@ -0,0 +1,288 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	em_errors "github.com/yfjiang-danny/eastmoneyapi/errors"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+)
+
+// 在当日委托中查找委托时，委托时间允许比本地的提交时间早的范围，用于容忍本地与券商的时钟误差
+const orderMatchSkew = 10 * time.Second
+
+// ClientOrderStatus 客户端委托的状态
+type ClientOrderStatus string
+
+const (
+	// ClientOrderPending 正在提交
+	ClientOrderPending ClientOrderStatus = "pending"
+	// ClientOrderSubmitted 已提交，OrderId 为券商的委托编号
+	ClientOrderSubmitted ClientOrderStatus = "submitted"
+	// ClientOrderRejected 被风控或者券商拒绝，委托没有提交，可以使用相同的编号重新提交
+	ClientOrderRejected ClientOrderStatus = "rejected"
+	// ClientOrderUnknown 无法确定委托是否已经提交
+	ClientOrderUnknown ClientOrderStatus = "unknown"
+)
+
+// ClientOrder 本地记录的客户端委托
+type ClientOrder struct {
+	ClientOrderId string
+	// 券商的委托编号，提交成功或者在当日委托中找到后填写
+	OrderId string
+	Status  ClientOrderStatus
+	// 最后一次提交的委托（可能被 MaxQuantityPolicy 调整了数量）
+	Form model.TradeOrderForm
+	// 第一次提交的时间，在当日委托中只查找此后的委托
+	SubmittedAt time.Time
+}
+
+// NewClientOrderId 生成客户端委托编号
+func NewClientOrderId() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return time.Now().Format("150405") + hex.EncodeToString(b)
+}
+
+// 东财的日期为北京时间，委托只在当日有效
+var cst = time.FixedZone("CST", 8*3600)
+
+// clientOrders 客户端委托编号与委托编号的对应关系。
+// 委托只在当日有效，进入新的交易日后第一次提交时清除之前的记录，避免长期运行时无限增长
+type clientOrders struct {
+	mu        sync.Mutex
+	orders    map[string]*ClientOrder
+	byOrderId map[string]string
+	inflight  map[string]bool
+	// 记录所属的日期，如 20231121
+	day string
+	now func() time.Time
+}
+
+func newClientOrders() *clientOrders {
+	return &clientOrders{
+		orders:    make(map[string]*ClientOrder),
+		byOrderId: make(map[string]string),
+		inflight:  make(map[string]bool),
+		now:       time.Now,
+	}
+}
+
+// prune 日期变化后清除之前交易日的记录，正在提交的委托除外
+func (c *clientOrders) prune() {
+	day := c.now().In(cst).Format("20060102")
+	if day == c.day {
+		return
+	}
+	c.day = day
+	for id, co := range c.orders {
+		if c.inflight[id] {
+			continue
+		}
+		delete(c.orders, id)
+		if co.OrderId != "" {
+			delete(c.byOrderId, co.OrderId)
+		}
+	}
+}
+
+// begin 开始提交，相同编号的委托正在提交时返回 ErrClientOrderInFlight，返回的是记录的副本
+func (c *clientOrders) begin(order model.TradeOrderForm) (ClientOrder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune()
+	if c.inflight[order.ClientOrderId] {
+		return ClientOrder{}, ErrClientOrderInFlight
+	}
+	c.inflight[order.ClientOrderId] = true
+	co, ok := c.orders[order.ClientOrderId]
+	if !ok {
+		co = &ClientOrder{ClientOrderId: order.ClientOrderId, Status: ClientOrderPending, Form: order}
+		c.orders[order.ClientOrderId] = co
+	}
+	return *co, nil
+}
+
+// reserve 开始查找已有的客户端委托，没有记录时返回 false；相同编号的委托正在提交时返回 ErrClientOrderInFlight
+func (c *clientOrders) reserve(clientOrderId string) (ClientOrder, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	co, ok := c.orders[clientOrderId]
+	if !ok {
+		return ClientOrder{}, false, nil
+	}
+	if c.inflight[clientOrderId] {
+		return ClientOrder{}, false, ErrClientOrderInFlight
+	}
+	c.inflight[clientOrderId] = true
+	return *co, true, nil
+}
+
+func (c *clientOrders) end(clientOrderId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inflight, clientOrderId)
+}
+
+// attempt 记录即将提交的委托，第一次提交时记录提交时间
+func (c *clientOrders) attempt(clientOrderId string, order model.TradeOrderForm) ClientOrder {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	co := c.orders[clientOrderId]
+	co.Form = order
+	co.Status = ClientOrderPending
+	if co.SubmittedAt.IsZero() {
+		co.SubmittedAt = time.Now()
+	}
+	return *co
+}
+
+func (c *clientOrders) setStatus(clientOrderId string, status ClientOrderStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.orders[clientOrderId].Status = status
+}
+
+func (c *clientOrders) link(clientOrderId, orderId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	co := c.orders[clientOrderId]
+	co.OrderId = orderId
+	co.Status = ClientOrderSubmitted
+	c.byOrderId[orderId] = clientOrderId
+}
+
+func (c *clientOrders) linked(orderId string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.byOrderId[orderId]
+	return ok
+}
+
+// ClientOrder 根据客户端委托编号查询本地记录的委托，只保留当日的记录
+func (e *EastMoneyClient) ClientOrder(clientOrderId string) (ClientOrder, bool) {
+	e.clientOrders.mu.Lock()
+	defer e.clientOrders.mu.Unlock()
+	co, ok := e.clientOrders.orders[clientOrderId]
+	if !ok {
+		return ClientOrder{}, false
+	}
+	return *co, true
+}
+
+// ClientOrderId 根据券商的委托编号查询客户端委托编号，不是通过本客户端提交的委托返回空字符串
+func (e *EastMoneyClient) ClientOrderId(orderId string) string {
+	e.clientOrders.mu.Lock()
+	defer e.clientOrders.mu.Unlock()
+	return e.clientOrders.byOrderId[orderId]
+}
+
+// FindClientOrder 查找客户端委托对应的委托编号，只查找，不会提交委托
+func (e *EastMoneyClient) FindClientOrder(clientOrderId string) (string, error) {
+	return e.FindClientOrderContext(context.Background(), clientOrderId)
+}
+
+// FindClientOrderContext 查找客户端委托对应的委托编号，只查找，不会提交委托。
+// 已提交时直接返回委托编号；提交结果未知时在当日委托中查找（见 SubmitLookups），找到后关联；
+// 没有找到、被拒绝或者没有该客户端委托的记录时返回空字符串，正在提交时返回 ErrClientOrderInFlight
+func (e *EastMoneyClient) FindClientOrderContext(ctx context.Context, clientOrderId string) (string, error) {
+	co, ok, err := e.clientOrders.reserve(clientOrderId)
+	if err != nil || !ok {
+		return "", err
+	}
+	defer e.clientOrders.end(clientOrderId)
+	switch co.Status {
+	case ClientOrderSubmitted:
+		return co.OrderId, nil
+	case ClientOrderUnknown:
+	default:
+		return "", nil
+	}
+	orderId, err := e.lookupSubmittedOrder(ctx, co)
+	if err != nil || orderId == "" {
+		return "", err
+	}
+	e.clientOrders.link(clientOrderId, orderId)
+	return orderId, nil
+}
+
+// 提交结果未知时在当日委托中查找的默认次数和间隔
+const (
+	defaultSubmitLookups        = 3
+	defaultSubmitLookupInterval = 500 * time.Millisecond
+)
+
+// lookupSubmittedOrder 按 SubmitLookups、SubmitLookupInterval 多次查找提交结果未知的委托，找到时立即返回。
+// 都没有找到时以最后一次查找的结果为准：最后一次查找失败时返回错误，不能认为委托没有到达券商
+func (e *EastMoneyClient) lookupSubmittedOrder(ctx context.Context, co ClientOrder) (string, error) {
+	lookups, interval := e.config.SubmitLookups, e.config.SubmitLookupInterval
+	if lookups <= 0 {
+		lookups = defaultSubmitLookups
+	}
+	if interval <= 0 {
+		interval = defaultSubmitLookupInterval
+	}
+	var orderId string
+	var err error
+	for i := 0; i < lookups; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+		orderId, err = e.matchSubmittedOrder(ctx, co)
+		if err == nil && orderId != "" {
+			return orderId, nil
+		}
+	}
+	return orderId, err
+}
+
+// matchSubmittedOrder 在当日委托中查找提交结果未知的委托：代码、方向、价格、数量相同，委托时间不早于第一次提交的时间，
+// 且没有与其他客户端委托关联。有多个时取最早的一个，没有找到时返回空字符串
+func (e *EastMoneyClient) matchSubmittedOrder(ctx context.Context, co ClientOrder) (string, error) {
+	orders, err := e.GetOrdersListContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	price := roundPrice(co.Form.Code, co.Form.Price)
+	since := co.SubmittedAt.Add(-orderMatchSkew)
+	var matched []*model.Order
+	for _, o := range orders {
+		if o.Code != co.Form.Code || o.TradeType() != co.Form.TradeType ||
+			!o.OrderPrice().Equal(price) || o.Amount() != co.Form.Amount ||
+			o.OrderTime().Before(since) || e.clientOrders.linked(o.OrderId) {
+			continue
+		}
+		matched = append(matched, o)
+	}
+	if len(matched) == 0 {
+		return "", nil
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].OrderTime().Before(matched[j].OrderTime())
+	})
+	return matched[0].OrderId, nil
+}
+
+// isAmbiguousSubmitError 提交委托的错误是否无法确定委托有没有到达券商。
+// 券商返回的业务错误、客户端已关闭、会话失效（请求被拒绝后重新登录失败）都说明委托没有提交
+func isAmbiguousSubmitError(err error) bool {
+	var brokerErr *em_errors.BrokerError
+	var loginErr *LoginError
+	switch {
+	case errors.As(err, &brokerErr), errors.As(err, &loginErr):
+		return false
+	case errors.Is(err, ErrClientClosed), errors.Is(err, ErrSessionExpired):
+		return false
+	}
+	return true
+}
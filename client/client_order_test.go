@@ -0,0 +1,48 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+)
+
+func TestClientOrdersPrune(t *testing.T) {
+	c := newClientOrders()
+	now := time.Date(2023, 11, 21, 14, 0, 0, 0, cst)
+	c.now = func() time.Time { return now }
+
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := c.begin(model.TradeOrderForm{ClientOrderId: id}); err != nil {
+			t.Fatal(err)
+		}
+		c.attempt(id, model.TradeOrderForm{ClientOrderId: id})
+	}
+	c.link("a", "1001")
+	c.setStatus("b", ClientOrderRejected)
+	c.end("a")
+	c.end("b")
+	// c 仍在提交中
+
+	// 同一天不清除
+	now = now.Add(time.Hour)
+	if _, err := c.begin(model.TradeOrderForm{ClientOrderId: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	c.end("d")
+	if len(c.orders) != 4 || !c.linked("1001") {
+		t.Fatalf("同一天不应该清除记录，剩余 %d 条", len(c.orders))
+	}
+
+	// 进入下一个交易日（北京时间），正在提交的委托保留
+	now = time.Date(2023, 11, 21, 16, 30, 0, 0, time.UTC)
+	if _, err := c.begin(model.TradeOrderForm{ClientOrderId: "e"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.orders) != 2 || c.orders["c"] == nil || c.orders["e"] == nil {
+		t.Fatalf("应该只剩下 c 和 e，实际为 %v", c.orders)
+	}
+	if c.linked("1001") || len(c.byOrderId) != 0 {
+		t.Fatal("之前交易日的委托编号没有清除")
+	}
+}
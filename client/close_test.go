@@ -0,0 +1,151 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func TestCloseRejectsRequests(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.GetOrdersList(); !errors.Is(err, client.ErrClientClosed) {
+		t.Fatalf("关闭后的请求应该返回 ErrClientClosed，实际为 %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("重复关闭应该返回 nil，实际为 %v", err)
+	}
+	if s.SessionCount() != 1 {
+		t.Fatal("没有配置 LogoutOnClose 时不应该退出登录")
+	}
+}
+
+func TestLogoutOnClose(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{LogoutOnClose: true})
+	if s.SessionCount() != 1 {
+		t.Fatal("没有登录")
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s.SessionCount() != 0 {
+		t.Fatal("LogoutOnClose 时关闭应该退出登录")
+	}
+}
+
+// newSlowProxy 转发请求到模拟服务，查询当日委托时先通知 started，再等待 delay
+func newSlowProxy(t *testing.T, s *fakebroker.Server, delay time.Duration, started chan<- struct{}) string {
+	t.Helper()
+	target, _ := url.Parse(s.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/Search/GetOrdersData" {
+			started <- struct{}{}
+			time.Sleep(delay)
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func TestShutdownWaitsForInflight(t *testing.T) {
+	s := fakebroker.New()
+	defer s.Close()
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	started := make(chan struct{}, 1)
+	e, err := client.NewEastMoneyClient(client.EastMoneyClientConfig{
+		Account:    testAccount,
+		BaseURL:    newSlowProxy(t, s, 300*time.Millisecond, started),
+		Recognizer: fakebroker.FixedRecognizer(s.Captcha()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := e.GetOrdersList()
+		result <- err
+	}()
+	<-started
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("关闭前开始的请求应该正常完成，实际为 %v", err)
+		}
+	default:
+		t.Fatal("Shutdown 没有等待正在进行的请求")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	s := fakebroker.New()
+	defer s.Close()
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	started := make(chan struct{}, 1)
+	e, err := client.NewEastMoneyClient(client.EastMoneyClientConfig{
+		Account:    testAccount,
+		BaseURL:    newSlowProxy(t, s, 500*time.Millisecond, started),
+		Recognizer: fakebroker.FixedRecognizer(s.Captcha()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go e.GetOrdersList()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := e.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("等待超时应该返回 ctx 的错误，实际为 %v", err)
+	}
+	if _, err := e.GetOrdersList(); !errors.Is(err, client.ErrClientClosed) {
+		t.Fatalf("超时后客户端仍然是关闭状态，实际为 %v", err)
+	}
+}
+
+func TestAccountManagerRemoveClosesClient(t *testing.T) {
+	s := fakebroker.New()
+	defer s.Close()
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	m := client.NewAccountManager()
+	c := client.EastMoneyClientConfig{Account: testAccount, BaseURL: s.URL, Recognizer: fakebroker.FixedRecognizer(s.Captcha())}
+	first, err := m.Add("first", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.Add("second", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("first"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.GetOrdersList(); !errors.Is(err, client.ErrClientClosed) {
+		t.Fatalf("移除的账户应该被关闭，实际为 %v", err)
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.GetOrdersList(); !errors.Is(err, client.ErrClientClosed) {
+		t.Fatalf("Shutdown 应该关闭所有账户，实际为 %v", err)
+	}
+	if len(m.Names()) != 0 {
+		t.Fatalf("Shutdown 后不应该还有账户: %v", m.Names())
+	}
+}
@@ -0,0 +1,57 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// blockingRecognizer 一直等待到 ctx 被取消
+type blockingRecognizer struct {
+	calls int
+}
+
+func (b *blockingRecognizer) Recognize(ctx context.Context, img []byte) (string, error) {
+	b.calls++
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestLoginContextCancel(t *testing.T) {
+	s := fakebroker.New()
+	defer s.Close()
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	r := &blockingRecognizer{}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.NewEastMoneyClientContext(ctx, client.EastMoneyClientConfig{Account: testAccount, BaseURL: s.URL, Recognizer: r})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("应该返回 context.DeadlineExceeded，实际为 %v", err)
+	}
+	if time.Since(start) > time.Second || r.calls != 1 {
+		t.Fatalf("ctx 取消后不应该继续重试登录: 耗时 %s，识别 %d 次", time.Since(start), r.calls)
+	}
+}
+
+func TestRequestContextCancel(t *testing.T) {
+	_, e := newTestClient(t, client.EastMoneyClientConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := e.GetOrdersListContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("查询应该返回 context.Canceled，实际为 %v", err)
+	}
+	if _, err := e.QueryAssetAndPositionContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("查询应该返回 context.Canceled，实际为 %v", err)
+	}
+	// 取消的请求不影响之后的请求
+	if _, err := e.GetOrdersListContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,401 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+	"github.com/yfjiang-danny/eastmoneyapi/risk"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const testAccount = "540000000001"
+
+func newTestClient(t *testing.T, c client.EastMoneyClientConfig) (*fakebroker.Server, *client.EastMoneyClient) {
+	t.Helper()
+	s := fakebroker.New()
+	t.Cleanup(s.Close)
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	c.Account = testAccount
+	c.BaseURL = s.URL
+	if c.Recognizer == nil {
+		c.Recognizer = fakebroker.FixedRecognizer(s.Captcha())
+	}
+	e, err := client.NewEastMoneyClient(c)
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return s, e
+}
+
+func buyForm(amount int) model.TradeOrderForm {
+	return model.TradeOrderForm{
+		Code:      "510300",
+		Name:      "沪深300ETF",
+		Price:     decimal.RequireFromString("3.856"),
+		Amount:    amount,
+		TradeType: model.TradeTypeBuy,
+	}
+}
+
+func findOrder(t *testing.T, e *client.EastMoneyClient, orderId string) *model.Order {
+	t.Helper()
+	orders, err := e.GetOrdersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range orders {
+		if o.OrderId == orderId {
+			return o
+		}
+	}
+	return nil
+}
+
+func TestLoginWrongCaptcha(t *testing.T) {
+	s := fakebroker.New()
+	defer s.Close()
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	_, err := client.NewEastMoneyClient(client.EastMoneyClientConfig{
+		Account:    testAccount,
+		BaseURL:    s.URL,
+		Recognizer: fakebroker.FixedRecognizer("0000"),
+	})
+	if !errors.Is(err, client.ErrCaptcha) {
+		t.Fatalf("验证码错误时应该返回 ErrCaptcha，实际为 %v", err)
+	}
+}
+
+func TestLoginWithoutRecognizer(t *testing.T) {
+	// 没有配置验证码识别方式时直接返回配置错误，不会尝试登录
+	s := fakebroker.New()
+	defer s.Close()
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	_, err := client.NewEastMoneyClient(client.EastMoneyClientConfig{Account: testAccount, BaseURL: s.URL})
+	if !errors.Is(err, client.ErrNoRecognizer) {
+		t.Fatalf("应该返回 ErrNoRecognizer，实际为 %v", err)
+	}
+	_, err = client.NewEastMoneyClient(client.EastMoneyClientConfig{Account: testAccount, BaseURL: s.URL, CaptchaTemplates: "not-exist.json"})
+	if err == nil {
+		t.Fatal("验证码模板加载失败时应该返回错误")
+	}
+	if s.SessionCount() != 0 {
+		t.Fatal("配置错误时不应该登录")
+	}
+}
+
+func TestSubmitAndRevoke(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+
+	orderId, err := e.SubmitTrade(buyForm(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := findOrder(t, e, orderId)
+	if o == nil {
+		t.Fatalf("当日委托中没有委托 %s", orderId)
+	}
+	if o.OrderStatus() != model.OrderStatusReported || o.Amount() != 1000 || o.TradeType() != model.TradeTypeBuy {
+		t.Fatalf("委托信息错误: %+v", o)
+	}
+
+	if err := s.Fill(orderId, 300, decimal.RequireFromString("3.855")); err != nil {
+		t.Fatal(err)
+	}
+	list, err := e.GetRevokeList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].OrderId != orderId {
+		t.Fatalf("可撤单列表错误: %+v", list)
+	}
+	results, err := e.RevokeOrdersDetailed(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Success {
+		t.Fatalf("撤单失败: %+v", results)
+	}
+	o = findOrder(t, e, orderId)
+	if o.OrderStatus() != model.OrderStatusPartFillRevoked || o.ClosingAmount() != 300 {
+		t.Fatalf("撤单后的状态错误: %s %d", o.Status, o.ClosingAmount())
+	}
+	if _, err := e.RevokeByOrderId(orderId); !errors.Is(err, client.ErrOrderNotRevocable) {
+		t.Fatalf("重复撤单应该返回 ErrOrderNotRevocable，实际为 %v", err)
+	}
+}
+
+func TestRiskChecksRoundedPrice(t *testing.T) {
+	_, e := newTestClient(t, client.EastMoneyClientConfig{
+		Risk: risk.Config{CheckPriceTick: true, MaxOrderValue: 3856},
+	})
+	// 原始价格不是最小变动单位的整数倍，金额也超过上限，四舍五入后的价格可以通过风控
+	order := buyForm(1000)
+	order.Price = decimal.RequireFromString("3.8564")
+	orderId, err := e.SubmitTrade(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := findOrder(t, e, orderId); o == nil || o.OrderPrice().String() != "3.856" {
+		t.Fatalf("委托价格错误: %+v", o)
+	}
+	if !e.Risk().Turnover().Equal(decimal.NewFromInt(3856)) {
+		t.Fatalf("当日累计金额应该按提交的价格计算: %s", e.Risk().Turnover())
+	}
+}
+
+func TestReloginAfterSessionExpired(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+	if _, err := e.SubmitTrade(buyForm(100)); err != nil {
+		t.Fatal(err)
+	}
+	s.ExpireSessions()
+	if s.SessionCount() != 0 {
+		t.Fatal("会话没有失效")
+	}
+	// 会话失效后的请求会自动重新登录并重放
+	orders, err := e.GetOrdersList()
+	if err != nil {
+		t.Fatalf("重新登录后查询失败: %v", err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("当日委托数量错误: %d", len(orders))
+	}
+	if s.SessionCount() != 1 {
+		t.Fatalf("应该重新登录一次，实际会话数 %d", s.SessionCount())
+	}
+	s.ExpireSessions()
+	if _, err := e.SubmitTrade(buyForm(100)); err != nil {
+		t.Fatalf("重新登录后提交委托失败: %v", err)
+	}
+}
+
+func TestAmbiguousSubmitProcessed(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{SubmitLookupInterval: 10 * time.Millisecond})
+	// 委托已经到达券商，但是响应丢失：在当日委托中找回，不会重复委托
+	s.DropSubmits(1, true)
+	form := buyForm(200)
+	form.ClientOrderId = "e2e-processed"
+	orderId, err := e.SubmitTrade(form)
+	if err != nil {
+		t.Fatalf("应该在当日委托中找回委托，实际返回 %v", err)
+	}
+	orders, err := e.GetOrdersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].OrderId != orderId {
+		t.Fatalf("委托重复或者没有找回: %+v", orders)
+	}
+	co, ok := e.ClientOrder("e2e-processed")
+	if !ok || co.Status != client.ClientOrderSubmitted || co.OrderId != orderId {
+		t.Fatalf("客户端委托记录错误: %+v", co)
+	}
+	if e.ClientOrderId(orderId) != "e2e-processed" {
+		t.Fatal("委托编号没有关联客户端委托编号")
+	}
+	// 相同的客户端委托编号再次提交直接返回原来的委托编号
+	again, err := e.SubmitTrade(form)
+	if err != nil || again != orderId {
+		t.Fatalf("重复提交应该返回 %s，实际为 %s %v", orderId, again, err)
+	}
+}
+
+func TestAmbiguousSubmitNotProcessed(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{SubmitLookupInterval: 10 * time.Millisecond})
+	// 委托没有到达券商，也没有重试：返回 AmbiguousSubmitError
+	s.DropSubmits(1, false)
+	form := buyForm(200)
+	form.ClientOrderId = "e2e-lost"
+	_, err := e.SubmitTrade(form)
+	var ambiguous *client.AmbiguousSubmitError
+	if !errors.As(err, &ambiguous) || !errors.Is(err, client.ErrOrderStatusUnknown) {
+		t.Fatalf("应该返回 AmbiguousSubmitError，实际为 %v", err)
+	}
+	// 使用相同的编号重新提交
+	orderId, err := e.SubmitTrade(form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, err := e.GetOrdersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].OrderId != orderId {
+		t.Fatalf("当日委托错误: %+v", orders)
+	}
+}
+
+func TestAmbiguousSubmitRetry(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{SubmitRetries: 1, SubmitLookupInterval: 10 * time.Millisecond})
+	s.DropSubmits(1, false)
+	orderId, err := e.SubmitTrade(buyForm(200))
+	if err != nil {
+		t.Fatalf("重试后应该提交成功，实际为 %v", err)
+	}
+	if o := findOrder(t, e, orderId); o == nil {
+		t.Fatalf("当日委托中没有委托 %s", orderId)
+	}
+}
+
+func TestAmbiguousSubmitListedLate(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{SubmitRetries: 1, SubmitLookupInterval: 10 * time.Millisecond})
+	// 委托已经到达券商，但是前两次查询当日委托时还没有出现：继续查找，不会重新提交
+	s.DropSubmits(1, true)
+	s.DelayOrders(2)
+	orderId, err := e.SubmitTrade(buyForm(200))
+	if err != nil {
+		t.Fatalf("应该在当日委托中找回委托，实际返回 %v", err)
+	}
+	orders, err := e.GetOrdersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].OrderId != orderId {
+		t.Fatalf("委托重复或者没有找回: %+v", orders)
+	}
+}
+
+func TestFindClientOrder(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{SubmitLookupInterval: 10 * time.Millisecond})
+	s.DropSubmits(1, true)
+	s.DelayOrders(3)
+	form := buyForm(200)
+	form.ClientOrderId = "e2e-find"
+	if _, err := e.SubmitTrade(form); !errors.Is(err, client.ErrOrderStatusUnknown) {
+		t.Fatalf("应该返回 AmbiguousSubmitError，实际为 %v", err)
+	}
+	// 只查找，不会提交委托
+	orderId, err := e.FindClientOrder("e2e-find")
+	if err != nil || orderId == "" {
+		t.Fatalf("应该在当日委托中找到委托，实际为 %q %v", orderId, err)
+	}
+	if co, _ := e.ClientOrder("e2e-find"); co.Status != client.ClientOrderSubmitted || co.OrderId != orderId {
+		t.Fatalf("找到后应该关联委托编号: %+v", co)
+	}
+	if again, err := e.FindClientOrder("e2e-find"); err != nil || again != orderId {
+		t.Fatalf("再次查找应该返回 %s，实际为 %q %v", orderId, again, err)
+	}
+	if orderId, err := e.FindClientOrder("e2e-none"); err != nil || orderId != "" {
+		t.Fatalf("没有记录的客户端委托应该返回空字符串，实际为 %q %v", orderId, err)
+	}
+	orders, err := e.GetOrdersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("查找时不应该提交委托: %+v", orders)
+	}
+}
+
+func TestAmbiguousSubmitLookupCanceled(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{SubmitLookupInterval: time.Minute})
+	s.DropSubmits(1, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := e.SubmitTradeContext(ctx, buyForm(200))
+	var ambiguous *client.AmbiguousSubmitError
+	if !errors.As(err, &ambiguous) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("取消查找后应该返回 AmbiguousSubmitError，实际为 %v", err)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{PageSize: 7})
+	const count = 23
+	for i := 0; i < count; i++ {
+		s.AddOrder(fakebroker.Order{
+			Account:   testAccount,
+			Code:      "510300",
+			Name:      "沪深300ETF",
+			TradeType: "B",
+			Price:     decimal.RequireFromString("3.856"),
+			Amount:    100 * (i + 1),
+		})
+	}
+	orders, err := e.GetOrdersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != count {
+		t.Fatalf("应该读取 %d 条委托，实际 %d 条", count, len(orders))
+	}
+	seen := make(map[string]bool, count)
+	for _, o := range orders {
+		if seen[o.OrderId] {
+			t.Fatalf("委托 %s 重复", o.OrderId)
+		}
+		seen[o.OrderId] = true
+	}
+
+	var pages int
+	it := e.OrdersIterator(10)
+	for it.Next(context.Background()) {
+		pages++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if pages != 3 {
+		t.Fatalf("每页10条应该有3页，实际 %d 页", pages)
+	}
+
+	revokes, err := e.GetRevokeList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revokes) != count {
+		t.Fatalf("可撤单列表应该有 %d 条，实际 %d 条", count, len(revokes))
+	}
+}
+
+func TestHistoryOrdersAcrossChunks(t *testing.T) {
+	// 模拟服务的历史查询跨度不能超过30天，90天的查询需要拆分为多次
+	s, e := newTestClient(t, client.EastMoneyClientConfig{PageSize: 2})
+	cst := time.FixedZone("CST", 8*3600)
+	end := time.Date(2023, 3, 31, 0, 0, 0, 0, cst)
+	var ids []string
+	for _, daysAgo := range []int{89, 60, 59, 30, 29, 29, 29, 0} {
+		id := s.AddOrder(fakebroker.Order{
+			Account:   testAccount,
+			Date:      end.AddDate(0, 0, -daysAgo).Format("20060102"),
+			Code:      "510300",
+			Name:      "沪深300ETF",
+			TradeType: "B",
+			Price:     decimal.RequireFromString("3.856"),
+			Amount:    100,
+		})
+		ids = append(ids, id)
+	}
+	// 范围之外
+	s.AddOrder(fakebroker.Order{Account: testAccount, Date: "20221231", Code: "510300", TradeType: "B", Price: decimal.RequireFromString("3.856"), Amount: 100})
+	if err := s.Fill(ids[1], 100, decimal.RequireFromString("3.850")); err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err := e.GetHistoryOrders(end.AddDate(0, 0, -89), end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != len(ids) {
+		t.Fatalf("应该查询到 %d 条历史委托，实际 %d 条", len(ids), len(orders))
+	}
+	for i, o := range orders {
+		if o.OrderId != ids[i] {
+			t.Fatalf("第 %d 条历史委托为 %s，应该为 %s", i, o.OrderId, ids[i])
+		}
+	}
+	deals, err := e.GetHistoryDeals(end.AddDate(0, 0, -89), end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deals) != 1 || deals[0].OrderId != ids[1] {
+		t.Fatalf("历史成交错误: %+v", deals)
+	}
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"fmt"
+
+	em_errors "github.com/yfjiang-danny/eastmoneyapi/errors"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+)
+
+// 登录失败的原因，可以通过 errors.Is 判断
+var (
+	// ErrBadCredentials 账号或密码错误，不应该再重试，以免账号被锁定
+	ErrBadCredentials = errors.New("账号或密码错误")
+	// ErrCaptcha 验证码获取或识别失败，可以重试
+	ErrCaptcha = errors.New("验证码错误")
+	// ErrNetwork 网络错误，可以重试
+	ErrNetwork = errors.New("网络错误")
+	// ErrPageStructure 东财的页面或接口结构发生了变化，需要更新代码
+	ErrPageStructure = errors.New("页面结构发生变化")
+	// ErrLoginRejected 东财拒绝了登录请求，原因见错误信息
+	ErrLoginRejected = errors.New("登录被拒绝")
+)
+
+// ErrNoRecognizer 没有配置验证码识别方式（Recognizer、CaptchaTemplates、OCRHost 都为空）。
+// captcha 包没有内置模板：还没有用真实验证码训练和测量过的模板，不能在登录时默认使用
+var ErrNoRecognizer = errors.New("没有配置验证码识别方式")
+
+// ErrClientClosed 客户端已经关闭
+var ErrClientClosed = errors.New("客户端已关闭")
+
+// ErrSessionExpired 会话失效，重新登录后依旧失效
+var ErrSessionExpired = em_errors.ErrSessionExpired
+
+// ErrOrderNotRevocable 委托不在可撤单列表中，可能已经成交或撤销
+var ErrOrderNotRevocable = errors.New("委托不在可撤单列表中")
+
+// ErrOrderFilled 委托已经全部成交，不能再改单
+var ErrOrderFilled = errors.New("委托已全部成交")
+
+// ErrOrderStatusUnknown 无法确定委托是否已经提交到券商
+var ErrOrderStatusUnknown = errors.New("委托结果未知")
+
+// ErrClientOrderInFlight 相同客户端委托编号的委托正在提交中
+var ErrClientOrderInFlight = errors.New("客户端委托编号正在提交中")
+
+// AmbiguousSubmitError 提交委托时请求超时等原因导致无法确定委托是否已经提交，且当日委托中没有找到该委托。
+// 可以使用相同的 ClientOrderId 再次调用 SubmitTrade，会先在当日委托中查找，不会重复委托
+type AmbiguousSubmitError struct {
+	ClientOrderId string
+	Err           error
+}
+
+func (e *AmbiguousSubmitError) Error() string {
+	return fmt.Sprintf("%s(客户端委托编号 %s): %s", ErrOrderStatusUnknown.Error(), e.ClientOrderId, e.Err.Error())
+}
+
+func (e *AmbiguousSubmitError) Is(target error) bool {
+	return target == ErrOrderStatusUnknown
+}
+
+func (e *AmbiguousSubmitError) Unwrap() error {
+	return e.Err
+}
+
+// AmendError 改单时原委托已经撤单，但是没有提交新的委托：剩余数量不足一手（科创板不足200股），或者提交新的委托失败。
+// Unwrap 返回提交失败的原因，结果未知时 errors.Is(err, ErrOrderStatusUnknown) 成立，
+// 可以使用客户端委托编号 "amend-<原委托编号>" 调用 SubmitTrade 重新提交，不会重复委托
+type AmendError struct {
+	OrderId      string
+	FilledAmount int
+	Err          error
+}
+
+func (e *AmendError) Error() string {
+	return fmt.Sprintf("委托 %s 已撤单，已成交 %d，重新委托失败: %s", e.OrderId, e.FilledAmount, e.Err.Error())
+}
+
+func (e *AmendError) Unwrap() error {
+	return e.Err
+}
+
+// QuantityExceededError 委托数量超过了最大可买/可卖数量，
+// 买入时可以通过 errors.Is(err, ErrInsufficientFunds) 判断，卖出时为 ErrInsufficientPosition
+type QuantityExceededError struct {
+	Code      string
+	TradeType model.TradeType
+	Requested int
+	Max       int
+}
+
+func (e *QuantityExceededError) Error() string {
+	return fmt.Sprintf("%s 委托数量 %d 超过了最大可%s数量 %d", e.Code, e.Requested, tradeTypeName(e.TradeType), e.Max)
+}
+
+func (e *QuantityExceededError) Is(target error) bool {
+	if e.TradeType == model.TradeTypeBuy {
+		return target == em_errors.ErrInsufficientFunds
+	}
+	return target == em_errors.ErrInsufficientPosition
+}
+
+func tradeTypeName(t model.TradeType) string {
+	if t == model.TradeTypeBuy {
+		return "买"
+	}
+	return "卖"
+}
+
+// LoginError 登录错误，Kind 为上面定义的错误类型之一
+type LoginError struct {
+	Kind error
+	Err  error
+}
+
+func (e *LoginError) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *LoginError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *LoginError) Unwrap() error {
+	return e.Err
+}
+
+func newLoginError(kind error, err error) error {
+	return &LoginError{Kind: kind, Err: err}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+)
+
+// 历史查询默认的最大日期跨度（天），超过时自动拆分为多次查询
+const defaultHistoryMaxDays = 30
+
+const historyDateFormat = "2006-01-02"
+
+// GetHistoryOrders 查询 [begin, end] 期间的历史委托，只比较日期部分，日期按北京时间计算
+func (e *EastMoneyClient) GetHistoryOrders(begin, end time.Time) ([]*model.Order, error) {
+	return e.GetHistoryOrdersContext(context.Background(), begin, end)
+}
+
+// GetHistoryOrdersContext 查询 [begin, end] 期间的历史委托，只比较日期部分
+func (e *EastMoneyClient) GetHistoryOrdersContext(ctx context.Context, begin, end time.Time) ([]*model.Order, error) {
+	return e.getHistory(ctx, "/Search/GetHisOrdersData", begin, end)
+}
+
+// GetHistoryDeals 查询 [begin, end] 期间的历史成交，只比较日期部分，日期按北京时间计算
+func (e *EastMoneyClient) GetHistoryDeals(begin, end time.Time) ([]*model.Order, error) {
+	return e.GetHistoryDealsContext(context.Background(), begin, end)
+}
+
+// GetHistoryDealsContext 查询 [begin, end] 期间的历史成交，只比较日期部分
+func (e *EastMoneyClient) GetHistoryDealsContext(ctx context.Context, begin, end time.Time) ([]*model.Order, error) {
+	return e.getHistory(ctx, "/Search/GetHisDealData", begin, end)
+}
+
+// getHistory 按照最大日期跨度拆分查询，每段都会翻页读取全部数据
+func (e *EastMoneyClient) getHistory(ctx context.Context, path string, begin, end time.Time) ([]*model.Order, error) {
+	var result []*model.Order
+	err := e.eachHistoryPage(ctx, path, begin, end, func(data json.RawMessage) error {
+		var page []*model.Order
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		result = append(result, page...)
+		return nil
+	})
+	return result, err
+}
+
+// eachHistoryPage 按照最大日期跨度拆分查询，对每一页的原始数据调用 fn
+func (e *EastMoneyClient) eachHistoryPage(ctx context.Context, path string, begin, end time.Time, fn func(data json.RawMessage) error) error {
+	return e.forEachDateRange(begin, end, func(st, et time.Time) error {
+		var form = make(url.Values)
+		form.Set("st", st.Format(historyDateFormat))
+		form.Set("et", et.Format(historyDateFormat))
+		p := e.newPager(path, form, 0)
+		for {
+			data, err := p.next(ctx)
+			if err != nil {
+				return err
+			}
+			if data == nil {
+				return nil
+			}
+			if err := fn(data); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// forEachDateRange 将 [begin, end] 按照最大日期跨度拆分，依次调用 fn，日期和拆分的边界都按北京时间计算
+func (e *EastMoneyClient) forEachDateRange(begin, end time.Time, fn func(st, et time.Time) error) error {
+	begin = truncateDate(begin)
+	end = truncateDate(end)
+	if end.Before(begin) {
+		return errors.New("结束日期不能早于开始日期")
+	}
+	maxDays := e.config.HistoryMaxDays
+	if maxDays <= 0 {
+		maxDays = defaultHistoryMaxDays
+	}
+	for st := begin; !st.After(end); st = st.AddDate(0, 0, maxDays) {
+		et := st.AddDate(0, 0, maxDays-1)
+		if et.After(end) {
+			et = end
+		}
+		if err := fn(st, et); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncateDate 北京时间的当天零点，与服务器所在的时区无关
+func truncateDate(t time.Time) time.Time {
+	t = t.In(cst)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, cst)
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForEachDateRange(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2023, m, d, 0, 0, 0, 0, cst) }
+	cases := []struct {
+		name       string
+		maxDays    int
+		begin, end time.Time
+		want       []string
+	}{
+		{"同一天", 0, day(1, 1), day(1, 1), []string{"2023-01-01/2023-01-01"}},
+		{"默认30天", 0, day(1, 1), day(3, 15), []string{"2023-01-01/2023-01-30", "2023-01-31/2023-03-01", "2023-03-02/2023-03-15"}},
+		{"正好一段", 10, day(1, 1), day(1, 10), []string{"2023-01-01/2023-01-10"}},
+		{"忽略时间部分", 10, day(1, 1).Add(15 * time.Hour), day(1, 11).Add(time.Hour), []string{"2023-01-01/2023-01-10", "2023-01-11/2023-01-11"}},
+		// UTC 的 1月1日 20:00 是北京时间的1月2日
+		{"按北京时间取日期", 10, time.Date(2023, 1, 1, 20, 0, 0, 0, time.UTC), time.Date(2023, 1, 12, 15, 0, 0, 0, time.UTC),
+			[]string{"2023-01-02/2023-01-11", "2023-01-12/2023-01-12"}},
+		{"西五区的零点", 30, time.Date(2023, 1, 1, 0, 0, 0, 0, time.FixedZone("EST", -5*3600)), day(1, 1).Add(23 * time.Hour),
+			[]string{"2023-01-01/2023-01-01"}},
+	}
+	for _, c := range cases {
+		e := &EastMoneyClient{config: EastMoneyClientConfig{HistoryMaxDays: c.maxDays}}
+		var got []string
+		err := e.forEachDateRange(c.begin, c.end, func(st, et time.Time) error {
+			if st.Location() != cst || et.Location() != cst {
+				t.Fatalf("%s: 边界应该是北京时间", c.name)
+			}
+			got = append(got, st.Format(historyDateFormat)+"/"+et.Format(historyDateFormat))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: 拆分为 %v，应该为 %v", c.name, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Fatalf("%s: 拆分为 %v，应该为 %v", c.name, got, c.want)
+			}
+		}
+	}
+
+	e := &EastMoneyClient{}
+	if err := e.forEachDateRange(day(1, 2), day(1, 1), func(st, et time.Time) error { return nil }); err == nil {
+		t.Fatal("结束日期早于开始日期时应该返回错误")
+	}
+}
@@ -0,0 +1,86 @@
+package client_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// newLoginServer 模拟登录接口，authentication 为登录接口的响应，position 为持仓页面
+func newLoginServer(t *testing.T, authentication, position string) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Login/YZM", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("png")) })
+	mux.HandleFunc("/Login/Authentication", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(authentication)) })
+	mux.HandleFunc("/Search/Position", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(position)) })
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func TestLoginErrors(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	ok := `{"Status":0,"Errcode":0,"Message":""}`
+	cases := []struct {
+		name    string
+		baseURL string
+		kind    error
+		// 识别验证码的次数，获取验证码失败时为0
+		attempts int
+	}{
+		{"网络错误", closed.URL, client.ErrNetwork, 0},
+		{"页面结构变化", newLoginServer(t, ok, `<html><body></body></html>`), client.ErrPageStructure, 5},
+		{"没有 value 属性", newLoginServer(t, ok, `<html><input id="em_validatekey"/></html>`), client.ErrPageStructure, 5},
+		{"账号锁定", newLoginServer(t, `{"Status":-1,"Errcode":-1,"Message":"账号已锁定"}`, ""), client.ErrBadCredentials, 1},
+		{"其他原因", newLoginServer(t, `{"Status":-1,"Errcode":-1,"Message":"系统维护"}`, ""), client.ErrLoginRejected, 5},
+		{"响应格式错误", newLoginServer(t, `<html></html>`, ""), client.ErrPageStructure, 5},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &recordingRecognizer{code: "1234"}
+			_, err := client.NewEastMoneyClient(client.EastMoneyClientConfig{Account: testAccount, BaseURL: c.baseURL, Recognizer: r})
+			var loginErr *client.LoginError
+			if !errors.As(err, &loginErr) || !errors.Is(err, c.kind) {
+				t.Fatalf("应该返回 %v，实际为 %v", c.kind, err)
+			}
+			if len(r.images) != c.attempts {
+				t.Fatalf("应该尝试登录 %d 次，实际 %d 次", c.attempts, len(r.images))
+			}
+		})
+	}
+}
+
+func TestLoginBadCredentialsNotRetried(t *testing.T) {
+	s := fakebroker.New()
+	defer s.Close()
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	r := &recordingRecognizer{code: s.Captcha()}
+	_, err := client.NewEastMoneyClient(client.EastMoneyClientConfig{Account: "540000000002", BaseURL: s.URL, Recognizer: r})
+	if !errors.Is(err, client.ErrBadCredentials) {
+		t.Fatalf("账号错误时应该返回 ErrBadCredentials，实际为 %v", err)
+	}
+	if len(r.images) != 1 {
+		t.Fatalf("账号或密码错误时不应该重试，实际登录了 %d 次", len(r.images))
+	}
+}
+
+func TestLoginInvalidCaptchaFormat(t *testing.T) {
+	s := fakebroker.New()
+	defer s.Close()
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	// 识别出字母或者位数不对时不提交登录请求
+	_, err := client.NewEastMoneyClient(client.EastMoneyClientConfig{Account: testAccount, BaseURL: s.URL, Recognizer: fakebroker.FixedRecognizer("12a4")})
+	if !errors.Is(err, client.ErrCaptcha) {
+		t.Fatalf("应该返回 ErrCaptcha，实际为 %v", err)
+	}
+	if s.SessionCount() != 0 {
+		t.Fatal("不应该登录成功")
+	}
+}
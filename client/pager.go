@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+)
+
+// 默认每页的数量
+const defaultPageSize = 100
+
+// pager 东财查询接口的翻页：每次请求 qqhs 条数据，请求下一页时 dwc 为上一页最后一条数据的 Dwc（定位串），
+// 返回的数据少于 qqhs 条或者定位串为空时说明已经没有下一页了
+type pager struct {
+	e        *EastMoneyClient
+	path     string
+	form     url.Values
+	pageSize int
+	dwc      string
+	done     bool
+}
+
+func (e *EastMoneyClient) newPager(path string, form url.Values, pageSize int) *pager {
+	if pageSize <= 0 {
+		pageSize = e.config.PageSize
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	return &pager{e: e, path: path, form: form, pageSize: pageSize}
+}
+
+// next 请求下一页，返回原始的 Data，没有下一页时返回 nil
+func (p *pager) next(ctx context.Context) (json.RawMessage, error) {
+	if p.done {
+		return nil, nil
+	}
+	form := make(url.Values, len(p.form)+2)
+	for k, v := range p.form {
+		form[k] = v
+	}
+	form.Set("qqhs", strconv.Itoa(p.pageSize))
+	form.Set("dwc", p.dwc)
+	resp, err := p.e.postForm(ctx, p.path, form)
+	if err != nil {
+		return nil, err
+	}
+	var data json.RawMessage
+	if err := bindResponse(resp, &data); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Dwc string `json:"Dwc"`
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, err
+		}
+	}
+	if len(rows) < p.pageSize || rows[len(rows)-1].Dwc == "" || rows[len(rows)-1].Dwc == p.dwc {
+		p.done = true
+	} else {
+		p.dwc = rows[len(rows)-1].Dwc
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// OrderIterator 委托/成交查询的翻页迭代器
+//
+//	it := c.OrdersIterator(0)
+//	for it.Next(ctx) {
+//		for _, o := range it.Page() {
+//			...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type OrderIterator struct {
+	p    *pager
+	page []*model.Order
+	err  error
+}
+
+// Next 请求下一页，没有下一页或者出错时返回 false
+func (it *OrderIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	data, err := it.p.next(ctx)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if data == nil {
+		return false
+	}
+	it.page = nil
+	if it.err = json.Unmarshal(data, &it.page); it.err != nil {
+		return false
+	}
+	return true
+}
+
+// Page 当前页的数据
+func (it *OrderIterator) Page() []*model.Order {
+	return it.page
+}
+
+// Err 翻页过程中遇到的错误
+func (it *OrderIterator) Err() error {
+	return it.err
+}
+
+// All 读取剩余的所有数据
+func (it *OrderIterator) All(ctx context.Context) ([]*model.Order, error) {
+	var all []*model.Order
+	for it.Next(ctx) {
+		all = append(all, it.page...)
+	}
+	return all, it.Err()
+}
+
+// PositionIterator 持仓查询的翻页迭代器，用法与 OrderIterator 相同
+type PositionIterator struct {
+	p    *pager
+	page []*model.PositionDetail
+	err  error
+}
+
+// Next 请求下一页，没有下一页或者出错时返回 false
+func (it *PositionIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	data, err := it.p.next(ctx)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if data == nil {
+		return false
+	}
+	it.page = nil
+	if it.err = json.Unmarshal(data, &it.page); it.err != nil {
+		return false
+	}
+	return true
+}
+
+// Page 当前页的数据
+func (it *PositionIterator) Page() []*model.PositionDetail {
+	return it.page
+}
+
+// Err 翻页过程中遇到的错误
+func (it *PositionIterator) Err() error {
+	return it.err
+}
+
+// All 读取剩余的所有数据
+func (it *PositionIterator) All(ctx context.Context) ([]*model.PositionDetail, error) {
+	var all []*model.PositionDetail
+	for it.Next(ctx) {
+		all = append(all, it.page...)
+	}
+	return all, it.Err()
+}
+
+// OrdersIterator 当日委托的翻页迭代器，pageSize 为0时使用配置的 PageSize
+func (e *EastMoneyClient) OrdersIterator(pageSize int) *OrderIterator {
+	return &OrderIterator{p: e.newPager("/Search/GetOrdersData", nil, pageSize)}
+}
+
+// DealsIterator 当日成交的翻页迭代器，pageSize 为0时使用配置的 PageSize
+func (e *EastMoneyClient) DealsIterator(pageSize int) *OrderIterator {
+	return &OrderIterator{p: e.newPager("/Search/GetDealData", nil, pageSize)}
+}
+
+// RevokeListIterator 可撤单委托的翻页迭代器，pageSize 为0时使用配置的 PageSize
+func (e *EastMoneyClient) RevokeListIterator(pageSize int) *OrderIterator {
+	return &OrderIterator{p: e.newPager("/Trade/GetRevokeList", nil, pageSize)}
+}
+
+// StockListIterator 持仓的翻页迭代器，pageSize 为0时使用配置的 PageSize
+func (e *EastMoneyClient) StockListIterator(pageSize int) *PositionIterator {
+	return &PositionIterator{p: e.newPager("/Search/GetStockList", nil, pageSize)}
+}
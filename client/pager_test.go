@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// newPagerServer 模拟翻页接口，total 为数据总数，stuckDwc 为 true 时每一页最后一条的定位串都相同
+func newPagerServer(t *testing.T, total int, stuckDwc bool) (*EastMoneyClient, *[]string) {
+	t.Helper()
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		requests = append(requests, r.PostForm.Get("qqhs")+"/"+r.PostForm.Get("dwc"))
+		size, _ := strconv.Atoi(r.PostForm.Get("qqhs"))
+		start, _ := strconv.Atoi(r.PostForm.Get("dwc"))
+		body := `{"Status":0,"Data":[`
+		for i := start; i < start+size && i < total; i++ {
+			if i > start {
+				body += ","
+			}
+			dwc := i + 1
+			if stuckDwc {
+				dwc = size
+			}
+			body += fmt.Sprintf(`{"Wtbh":"%d","Dwc":"%d"}`, i+1, dwc)
+		}
+		w.Write([]byte(body + "]}"))
+	}))
+	t.Cleanup(ts.Close)
+	return &EastMoneyClient{cli: ts.Client(), baseUrl: ts.URL}, &requests
+}
+
+func TestPagerFollowsDwc(t *testing.T) {
+	cases := []struct {
+		total    int
+		pageSize int
+		requests []string
+	}{
+		{0, 10, []string{"10/"}},
+		{7, 10, []string{"10/"}},
+		// 数据总数是每页数量的整数倍时，最后会多请求一次空页
+		{20, 10, []string{"10/", "10/10", "10/20"}},
+		{23, 10, []string{"10/", "10/10", "10/20"}},
+	}
+	for _, c := range cases {
+		e, requests := newPagerServer(t, c.total, false)
+		orders, err := e.OrdersIterator(c.pageSize).All(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(orders) != c.total {
+			t.Fatalf("total=%d: 读取了 %d 条", c.total, len(orders))
+		}
+		for i, o := range orders {
+			if o.OrderId != strconv.Itoa(i+1) {
+				t.Fatalf("total=%d: 第 %d 条为 %s", c.total, i, o.OrderId)
+			}
+		}
+		if fmt.Sprint(*requests) != fmt.Sprint(c.requests) {
+			t.Fatalf("total=%d: 请求为 %v，应该为 %v", c.total, *requests, c.requests)
+		}
+	}
+}
+
+func TestPagerStopsOnRepeatedDwc(t *testing.T) {
+	// 定位串没有变化时停止翻页，不会一直请求同一页
+	e, requests := newPagerServer(t, 100, true)
+	orders, err := e.OrdersIterator(10).All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 20 || len(*requests) != 2 {
+		t.Fatalf("读取了 %d 条，请求了 %d 次", len(orders), len(*requests))
+	}
+}
+
+func TestPagerDefaultPageSize(t *testing.T) {
+	e, requests := newPagerServer(t, 1, false)
+	if _, err := e.StockListIterator(0).All(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	e.config.PageSize = 50
+	if _, err := e.DealsIterator(0).All(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if (*requests)[0] != "100/" || (*requests)[1] != "50/" {
+		t.Fatalf("每页数量错误: %v", *requests)
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+	"github.com/yfjiang-danny/eastmoneyapi/util"
+
+	"github.com/shopspring/decimal"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// MaxQuantityPolicy 提交委托时委托数量超过最大可买/可卖数量的处理方式
+type MaxQuantityPolicy string
+
+const (
+	// MaxQuantityIgnore 不检查，直接提交，由东财返回资金或股份不足的错误
+	MaxQuantityIgnore MaxQuantityPolicy = ""
+	// MaxQuantityClamp 将委托数量减少为最大可买/可卖数量，最大数量为0时返回 *QuantityExceededError
+	MaxQuantityClamp MaxQuantityPolicy = "clamp"
+	// MaxQuantityReject 返回 *QuantityExceededError，不提交委托
+	MaxQuantityReject MaxQuantityPolicy = "reject"
+)
+
+// GetMaxTradeQuantity 查询指定价格的最大可买/可卖数量，与网页版下单页面显示的可买、可卖数量相同，
+// 可买数量已经扣除了冻结资金和交易费用
+func (e *EastMoneyClient) GetMaxTradeQuantity(code string, price decimal.Decimal, tradeType model.TradeType) (*model.MaxTradeQuantity, error) {
+	return e.GetMaxTradeQuantityContext(context.Background(), code, price, tradeType)
+}
+
+// GetMaxTradeQuantityContext 查询指定价格的最大可买/可卖数量
+func (e *EastMoneyClient) GetMaxTradeQuantityContext(ctx context.Context, code string, price decimal.Decimal, tradeType model.TradeType) (*model.MaxTradeQuantity, error) {
+	var form = make(url.Values, 0)
+	form.Add("stockCode", code)
+	form.Add("price", price.String())
+	form.Add("tradeType", string(tradeType))
+	form.Add("market", util.GetMarket(code))
+	resp, err := e.postForm(ctx, "/Trade/GetKyzjAndKml", form)
+	if err != nil {
+		return nil, err
+	}
+	var result model.MaxTradeQuantity
+	if err := bindResponse(resp, &result); err != nil {
+		return nil, err
+	}
+	result.Code = code
+	result.TradeType = tradeType
+	result.Price = price
+	return &result, nil
+}
+
+// checkMaxQuantity 根据 MaxQuantityPolicy 检查委托数量，返回可能被减少了数量的委托
+func (e *EastMoneyClient) checkMaxQuantity(ctx context.Context, order model.TradeOrderForm) (model.TradeOrderForm, error) {
+	policy := e.config.MaxQuantityPolicy
+	if policy == MaxQuantityIgnore {
+		return order, nil
+	}
+	max, err := e.GetMaxTradeQuantityContext(ctx, order.Code, order.Price, order.TradeType)
+	if err != nil {
+		return order, err
+	}
+	if order.Amount <= max.Quantity {
+		return order, nil
+	}
+	exceeded := &QuantityExceededError{
+		Code:      order.Code,
+		TradeType: order.TradeType,
+		Requested: order.Amount,
+		Max:       max.Quantity,
+	}
+	if policy != MaxQuantityClamp || max.Quantity <= 0 {
+		return order, exceeded
+	}
+	logrus.Warnf("%s，委托数量调整为 %d", exceeded.Error(), max.Quantity)
+	order.Amount = max.Quantity
+	return order, nil
+}
@@ -0,0 +1,112 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	em_errors "github.com/yfjiang-danny/eastmoneyapi/errors"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func TestGetMaxTradeQuantity(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+	if err := s.AddPosition(testAccount, "510300", "沪深300ETF", 1500, decimal.RequireFromString("3.800")); err != nil {
+		t.Fatal(err)
+	}
+	price := decimal.RequireFromString("3.856")
+	max, err := e.GetMaxTradeQuantity("510300", price, model.TradeTypeBuy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1000000 / 3.856 = 259336，按手取整
+	if max.Quantity != 259300 || !max.AvailableFund.Equal(decimal.NewFromInt(1000000)) {
+		t.Fatalf("最大可买数量错误: %+v", max)
+	}
+	if max.Code != "510300" || max.TradeType != model.TradeTypeBuy || !max.Price.Equal(price) {
+		t.Fatalf("没有填写查询条件: %+v", max)
+	}
+	max, err = e.GetMaxTradeQuantity("510300", price, model.TradeTypeSale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if max.Quantity != 1500 {
+		t.Fatalf("最大可卖数量为 %d，应该为 1500", max.Quantity)
+	}
+}
+
+func TestMaxQuantityPolicy(t *testing.T) {
+	sell := func(amount int) model.TradeOrderForm {
+		o := buyForm(amount)
+		o.TradeType = model.TradeTypeSale
+		return o
+	}
+	cases := []struct {
+		name   string
+		policy client.MaxQuantityPolicy
+		order  model.TradeOrderForm
+		// 提交后的委托数量，为0时应该返回 *QuantityExceededError
+		amount int
+		is     error
+	}{
+		{"不超过时不调整", client.MaxQuantityReject, buyForm(1000), 1000, nil},
+		{"不检查", client.MaxQuantityIgnore, sell(1000), 1000, nil},
+		{"买入超过时拒绝", client.MaxQuantityReject, buyForm(300000), 0, em_errors.ErrInsufficientFunds},
+		{"买入超过时减少数量", client.MaxQuantityClamp, buyForm(300000), 259300, nil},
+		{"卖出超过时减少数量", client.MaxQuantityClamp, sell(1000), 500, nil},
+		{"卖出超过时拒绝", client.MaxQuantityReject, sell(1000), 0, em_errors.ErrInsufficientPosition},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, e := newTestClient(t, client.EastMoneyClientConfig{MaxQuantityPolicy: c.policy})
+			if err := s.AddPosition(testAccount, "510300", "沪深300ETF", 500, decimal.RequireFromString("3.800")); err != nil {
+				t.Fatal(err)
+			}
+			orderId, err := e.SubmitTrade(c.order)
+			if c.amount == 0 {
+				var exceeded *client.QuantityExceededError
+				if !errors.As(err, &exceeded) || !errors.Is(err, c.is) {
+					t.Fatalf("应该返回 QuantityExceededError: %v", err)
+				}
+				if exceeded.Requested != c.order.Amount {
+					t.Fatalf("委托数量为 %d，应该为 %d", exceeded.Requested, c.order.Amount)
+				}
+				orders, err := e.GetOrdersList()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(orders) != 0 {
+					t.Fatalf("被拒绝的委托不应该提交: %+v", orders)
+				}
+				return
+			}
+			if c.policy == client.MaxQuantityIgnore {
+				// 不检查时由柜台返回股份不足
+				if !errors.Is(err, em_errors.ErrInsufficientPosition) {
+					t.Fatalf("应该返回股份不足: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := findOrder(t, e, orderId)
+			if o == nil || o.Amount() != c.amount {
+				t.Fatalf("委托数量错误: %+v，应该为 %d", o, c.amount)
+			}
+		})
+	}
+}
+
+func TestMaxQuantityClampToZero(t *testing.T) {
+	_, e := newTestClient(t, client.EastMoneyClientConfig{MaxQuantityPolicy: client.MaxQuantityClamp})
+	order := buyForm(100)
+	order.TradeType = model.TradeTypeSale
+	_, err := e.SubmitTrade(order)
+	var exceeded *client.QuantityExceededError
+	if !errors.As(err, &exceeded) || exceeded.Max != 0 {
+		t.Fatalf("没有持仓时应该返回 QuantityExceededError: %v", err)
+	}
+}
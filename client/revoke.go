@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+)
+
+// 撤单结果中表示失败的关键字，优先于成功的关键字
+var revokeFailedKeywords = []string{"失败", "不能", "不允许", "错误", "不存在"}
+
+// 撤单结果中表示成功的关键字
+var revokeSuccessKeywords = []string{"成功", "已提交", "已申报"}
+
+// RevokeOrdersDetailed 撤单，返回每个委托的撤单结果，顺序与 list 相同
+func (e *EastMoneyClient) RevokeOrdersDetailed(list []*model.Order) ([]model.RevokeResult, error) {
+	return e.RevokeOrdersDetailedContext(context.Background(), list)
+}
+
+// RevokeOrdersDetailedContext 撤单，返回每个委托的撤单结果，顺序与 list 相同
+func (e *EastMoneyClient) RevokeOrdersDetailedContext(ctx context.Context, list []*model.Order) ([]model.RevokeResult, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	raw, err := e.RevokeOrdersContext(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	return parseRevokeResult(raw, list), nil
+}
+
+// RevokeAll 撤销可撤单列表中所有满足 filter 的委托，filter 为 nil 时撤销全部
+func (e *EastMoneyClient) RevokeAll(filter func(o *model.Order) bool) ([]model.RevokeResult, error) {
+	return e.RevokeAllContext(context.Background(), filter)
+}
+
+// RevokeAllContext 撤销可撤单列表中所有满足 filter 的委托，filter 为 nil 时撤销全部
+func (e *EastMoneyClient) RevokeAllContext(ctx context.Context, filter func(o *model.Order) bool) ([]model.RevokeResult, error) {
+	list, err := e.GetRevokeListContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var revokes []*model.Order
+	for _, o := range list {
+		if filter == nil || filter(o) {
+			revokes = append(revokes, o)
+		}
+	}
+	return e.RevokeOrdersDetailedContext(ctx, revokes)
+}
+
+// RevokeByOrderId 根据委托编号撤单，委托日期从可撤单列表中获取，不在可撤单列表中时返回 ErrOrderNotRevocable
+func (e *EastMoneyClient) RevokeByOrderId(orderId string) (model.RevokeResult, error) {
+	return e.RevokeByOrderIdContext(context.Background(), orderId)
+}
+
+// RevokeByOrderIdContext 根据委托编号撤单
+func (e *EastMoneyClient) RevokeByOrderIdContext(ctx context.Context, orderId string) (model.RevokeResult, error) {
+	results, err := e.RevokeAllContext(ctx, func(o *model.Order) bool {
+		return o.OrderId == orderId
+	})
+	if err != nil {
+		return model.RevokeResult{}, err
+	}
+	if len(results) == 0 {
+		return model.RevokeResult{}, ErrOrderNotRevocable
+	}
+	return results[0], nil
+}
+
+// parseRevokeResult 解析撤单接口返回的字符串，格式为 "委托编号: 消息"，多条之间使用三个空格分割
+func parseRevokeResult(raw string, list []*model.Order) []model.RevokeResult {
+	messages := make(map[string]string, len(list))
+	for _, item := range strings.Split(raw, "   ") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		idx := strings.IndexAny(item, ":：")
+		if idx < 0 {
+			continue
+		}
+		orderId := strings.TrimSpace(item[:idx])
+		// 部分情况下返回的是 日期_委托编号
+		if i := strings.LastIndex(orderId, "_"); i >= 0 {
+			orderId = orderId[i+1:]
+		}
+		msg := strings.TrimLeft(item[idx:], ":：")
+		messages[orderId] = strings.TrimSpace(msg)
+	}
+
+	results := make([]model.RevokeResult, 0, len(list))
+	for _, o := range list {
+		msg, ok := messages[o.OrderId]
+		if !ok {
+			results = append(results, model.RevokeResult{OrderId: o.OrderId, Message: "未返回撤单结果: " + raw})
+			continue
+		}
+		results = append(results, model.RevokeResult{
+			OrderId: o.OrderId,
+			Success: isRevokeSuccess(msg),
+			Message: msg,
+		})
+	}
+	return results
+}
+
+func isRevokeSuccess(msg string) bool {
+	for _, keyword := range revokeFailedKeywords {
+		if strings.Contains(msg, keyword) {
+			return false
+		}
+	}
+	for _, keyword := range revokeSuccessKeywords {
+		if strings.Contains(msg, keyword) {
+			return true
+		}
+	}
+	return false
+}
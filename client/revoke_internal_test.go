@@ -0,0 +1,48 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+)
+
+func TestParseRevokeResult(t *testing.T) {
+	list := []*model.Order{{OrderId: "101"}, {OrderId: "102"}, {OrderId: "103"}, {OrderId: "104"}}
+	raw := "101: 撤单委托已提交   20231121_102：撤单失败，该委托已成   103: 不允许撤单"
+	results := parseRevokeResult(raw, list)
+	want := []struct {
+		success bool
+		message string
+	}{
+		{true, "撤单委托已提交"},
+		{false, "撤单失败，该委托已成"},
+		{false, "不允许撤单"},
+		{false, "未返回撤单结果: " + raw},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("结果数量错误: %+v", results)
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.OrderId != list[i].OrderId || r.Success != w.success || r.Message != w.message {
+			t.Errorf("%s: 结果为 %+v，应该为 %+v", list[i].OrderId, r, w)
+		}
+	}
+}
+
+func TestIsRevokeSuccess(t *testing.T) {
+	cases := map[string]bool{
+		"撤单成功":        true,
+		"撤单委托已提交":     true,
+		"已申报":         true,
+		"撤单失败":        false,
+		"提交成功但委托不存在":  false,
+		"委托状态错误，不能撤单": false,
+		"":            false,
+	}
+	for msg, want := range cases {
+		if got := isRevokeSuccess(msg); got != want {
+			t.Errorf("%q: %v，应该为 %v", msg, got, want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func TestRevokeOrdersDetailedPartialFailure(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+	live, err := e.SubmitTrade(buyForm(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filled, err := e.SubmitTrade(buyForm(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := e.GetRevokeList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("可撤单列表错误: %+v", list)
+	}
+	// 查询可撤单列表之后、撤单之前全部成交
+	if err := s.Fill(filled, 200, decimal.RequireFromString("3.856")); err != nil {
+		t.Fatal(err)
+	}
+	results, err := e.RevokeOrdersDetailed(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("撤单结果数量错误: %+v", results)
+	}
+	for i, o := range list {
+		r := results[i]
+		if r.OrderId != o.OrderId || r.Message == "" {
+			t.Fatalf("撤单结果的顺序应该与委托相同: %+v", results)
+		}
+		if want := o.OrderId == live; r.Success != want {
+			t.Fatalf("委托 %s 撤单结果应该为 %v: %+v", o.OrderId, want, r)
+		}
+	}
+	if o := findOrder(t, e, live); o.OrderStatus() != model.OrderStatusRevoked {
+		t.Fatalf("委托 %s 应该已撤，实际为 %s", live, o.Status)
+	}
+}
+
+func TestRevokeAllFilter(t *testing.T) {
+	_, e := newTestClient(t, client.EastMoneyClientConfig{})
+	var ids []string
+	for _, amount := range []int{100, 200, 300} {
+		id, err := e.SubmitTrade(buyForm(amount))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	results, err := e.RevokeAll(func(o *model.Order) bool { return o.Amount() >= 200 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !results[0].Success || !results[1].Success {
+		t.Fatalf("应该撤销两个委托: %+v", results)
+	}
+	list, err := e.GetRevokeList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].OrderId != ids[0] {
+		t.Fatalf("没有满足条件的委托不应该撤销: %+v", list)
+	}
+	// 撤销全部
+	if results, err := e.RevokeAll(nil); err != nil || len(results) != 1 || !results[0].Success {
+		t.Fatalf("撤销全部失败: %+v %v", results, err)
+	}
+	if results, err := e.RevokeAll(nil); err != nil || len(results) != 0 {
+		t.Fatalf("没有可撤单的委托时应该返回空: %+v %v", results, err)
+	}
+}
+
+func TestRevokeByOrderId(t *testing.T) {
+	_, e := newTestClient(t, client.EastMoneyClientConfig{})
+	id, err := e.SubmitTrade(buyForm(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := e.RevokeByOrderId(id)
+	if err != nil || !r.Success || r.OrderId != id {
+		t.Fatalf("撤单失败: %+v %v", r, err)
+	}
+	if _, err := e.RevokeByOrderId("999999"); !errors.Is(err, client.ErrOrderNotRevocable) {
+		t.Fatalf("不存在的委托应该返回 ErrOrderNotRevocable，实际为 %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	em_errors "github.com/yfjiang-danny/eastmoneyapi/errors"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	logrus "github.com/sirupsen/logrus"
+)
+
+func (e *EastMoneyClient) setValidateKey(key string) {
+	e.sessionMu.Lock()
+	defer e.sessionMu.Unlock()
+	e.validateKey = key
+	e.generation++
+}
+
+// session 当前的 validatekey 和登录次数
+func (e *EastMoneyClient) session() (string, uint64) {
+	e.sessionMu.RLock()
+	defer e.sessionMu.RUnlock()
+	return e.validateKey, e.generation
+}
+
+// relogin 会话失效后重新登录，generation 为发起请求时的登录次数。
+// 多个请求同时发现会话失效时，只有第一个会重新登录，其余的等待登录完成后直接使用新的会话
+func (e *EastMoneyClient) relogin(ctx context.Context, generation uint64) error {
+	e.loginMu.Lock()
+	defer e.loginMu.Unlock()
+	if _, cur := e.session(); cur != generation {
+		return nil
+	}
+	logrus.Warnf("账号 %s 会话已失效，重新登录", e.config.Account)
+	return e.loginLocked(ctx)
+}
+
+// postForm 以表单的方式请求东财接口，自动带上 validatekey
+func (e *EastMoneyClient) postForm(ctx context.Context, path string, form url.Values) (*http.Response, error) {
+	body := ""
+	if form != nil {
+		body = form.Encode()
+	}
+	return e.request(ctx, func(validateKey string) (*http.Request, error) {
+		return createRequestWithBaseHeader(ctx, "POST", e.baseUrl+path+"?validatekey="+validateKey, strings.NewReader(body))
+	})
+}
+
+// postJson 以 json 的方式请求东财接口，自动带上 validatekey
+func (e *EastMoneyClient) postJson(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	return e.request(ctx, func(validateKey string) (*http.Request, error) {
+		return createRequestWithJson(ctx, "POST", e.baseUrl+path+"?validatekey="+validateKey, bytes.NewReader(body))
+	})
+}
+
+// request 发送请求，build 根据当前的 validatekey 构造请求。
+// 如果响应表明会话已失效，重新登录一次，再使用新的 validatekey 重放请求
+func (e *EastMoneyClient) request(ctx context.Context, build func(validateKey string) (*http.Request, error)) (*http.Response, error) {
+	validateKey, generation := e.session()
+	req, err := build(validateKey)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.do(req)
+	if err != nil || !isSessionExpired(resp) {
+		return resp, err
+	}
+
+	if err := e.relogin(ctx, generation); err != nil {
+		return nil, err
+	}
+	validateKey, _ = e.session()
+	if req, err = build(validateKey); err != nil {
+		return nil, err
+	}
+	if resp, err = e.do(req); err != nil {
+		return nil, err
+	}
+	if isSessionExpired(resp) {
+		return nil, ErrSessionExpired
+	}
+	return resp, nil
+}
+
+// do 发送请求并读取全部响应，客户端关闭后返回 ErrClientClosed
+func (e *EastMoneyClient) do(req *http.Request) (*http.Response, error) {
+	e.mu.RLock()
+	if e.closed {
+		e.mu.RUnlock()
+		return nil, ErrClientClosed
+	}
+	e.inflight.Add(1)
+	e.mu.RUnlock()
+	defer e.inflight.Done()
+
+	resp, err := e.cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// isSessionExpired 判断响应是否表明会话已失效：被重定向到了登录页面，或者 Status/Errcode 为会话失效的状态码，
+// 或者错误消息表明需要重新登录。resp.Body 必须是 do 返回的已缓存的响应
+func isSessionExpired(resp *http.Response) bool {
+	if resp.Request != nil && strings.HasPrefix(strings.ToLower(resp.Request.URL.Path), "/login") {
+		return true
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var result model.Response
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false
+	}
+	if result.Status == 0 {
+		return false
+	}
+	if int(result.Status) == em_errors.CodeSessionExpired || int(result.Errcode) == em_errors.CodeSessionExpired {
+		return true
+	}
+	return errors.Is(result.Err(), em_errors.ErrSessionExpired)
+}
@@ -0,0 +1,227 @@
+package client
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// Session 登录后的会话，保存后可以在重启时复用，避免每次启动都通过验证码登录
+type Session struct {
+	Account     string
+	ValidateKey string
+	Cookies     []*http.Cookie
+	SavedAt     time.Time
+}
+
+// SessionStore 会话存储，没有保存过会话时 Load 返回 nil, nil
+type SessionStore interface {
+	Load() (*Session, error)
+	Save(s *Session) error
+	Clear() error
+}
+
+// FileSessionStore 以 json 明文保存会话，文件权限为 0600
+type FileSessionStore struct {
+	Path string
+}
+
+func (f *FileSessionStore) Load() (*Session, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (f *FileSessionStore) Save(s *Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.Path, data)
+}
+
+func (f *FileSessionStore) Clear() error {
+	if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// EncryptedFileSessionStore 使用 AES-256-GCM 加密保存会话，密钥为 Key 的 sha256
+type EncryptedFileSessionStore struct {
+	Path string
+	Key  string
+}
+
+func (f *EncryptedFileSessionStore) Load() (*Session, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := f.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("会话文件已损坏")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "会话文件解密失败")
+	}
+	var s Session
+	if err := json.Unmarshal(plain, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (f *EncryptedFileSessionStore) Save(s *Session) error {
+	plain, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	gcm, err := f.gcm()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	return writeFileAtomic(f.Path, gcm.Seal(nonce, nonce, plain, nil))
+}
+
+func (f *EncryptedFileSessionStore) Clear() error {
+	if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *EncryptedFileSessionStore) gcm() (cipher.AEAD, error) {
+	if f.Key == "" {
+		return nil, errors.New("会话加密密钥为空")
+	}
+	key := sha256.Sum256([]byte(f.Key))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免写入一半时进程退出导致文件损坏
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (e *EastMoneyClient) sessionStore() SessionStore {
+	if e.config.SessionStore != nil {
+		return e.config.SessionStore
+	}
+	if e.config.SessionFile == "" {
+		return nil
+	}
+	if e.config.SessionKey != "" {
+		return &EncryptedFileSessionStore{Path: e.config.SessionFile, Key: e.config.SessionKey}
+	}
+	return &FileSessionStore{Path: e.config.SessionFile}
+}
+
+// saveSession 登录成功后保存会话
+func (e *EastMoneyClient) saveSession() {
+	store := e.sessionStore()
+	if store == nil {
+		return
+	}
+	u, _ := url.Parse(e.baseUrl)
+	validateKey, _ := e.session()
+	err := store.Save(&Session{
+		Account:     e.config.Account,
+		ValidateKey: validateKey,
+		Cookies:     e.cli.Jar.Cookies(u),
+		SavedAt:     time.Now(),
+	})
+	if err != nil {
+		logrus.Warnf("账号 %s 保存会话失败: %s", e.config.Account, err.Error())
+	}
+}
+
+// restoreSession 恢复保存的会话，并访问持仓页面检查会话是否依旧有效
+func (e *EastMoneyClient) restoreSession(ctx context.Context) bool {
+	store := e.sessionStore()
+	if store == nil {
+		return false
+	}
+	s, err := store.Load()
+	if err != nil {
+		logrus.Warnf("账号 %s 读取会话失败: %s", e.config.Account, err.Error())
+		return false
+	}
+	if s == nil || s.Account != e.config.Account {
+		return false
+	}
+	u, _ := url.Parse(e.baseUrl)
+	for _, c := range s.Cookies {
+		c.Path = "/"
+	}
+	e.cli.Jar.SetCookies(u, s.Cookies)
+	if err := e.getValidateKey(ctx); err != nil {
+		logrus.Infof("账号 %s 保存的会话已失效，重新登录", e.config.Account)
+		return false
+	}
+	return true
+}
+
+// clearSession 退出登录后删除保存的会话
+func (e *EastMoneyClient) clearSession() {
+	store := e.sessionStore()
+	if store == nil {
+		return
+	}
+	if err := store.Clear(); err != nil {
+		logrus.Warnf("账号 %s 删除会话失败: %s", e.config.Account, err.Error())
+	}
+}
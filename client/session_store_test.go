@@ -0,0 +1,119 @@
+package client_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+
+	"github.com/shopspring/decimal"
+)
+
+func newSessionBroker(t *testing.T) *fakebroker.Server {
+	t.Helper()
+	s := fakebroker.New()
+	t.Cleanup(s.Close)
+	s.AddAccount(testAccount, decimal.NewFromInt(1000000))
+	return s
+}
+
+// loginWithSession 使用会话文件创建客户端，返回客户端和识别验证码的次数
+func loginWithSession(t *testing.T, s *fakebroker.Server, c client.EastMoneyClientConfig) (*client.EastMoneyClient, int) {
+	t.Helper()
+	r := &recordingRecognizer{code: s.Captcha()}
+	c.Account, c.BaseURL, c.Recognizer = testAccount, s.URL, r
+	e, err := client.NewEastMoneyClient(c)
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e, len(r.images)
+}
+
+func TestSessionRestore(t *testing.T) {
+	for _, key := range []string{"", "secret"} {
+		s := newSessionBroker(t)
+		c := client.EastMoneyClientConfig{SessionFile: filepath.Join(t.TempDir(), "session.json"), SessionKey: key}
+		first, logins := loginWithSession(t, s, c)
+		if logins != 1 {
+			t.Fatalf("第一次启动应该登录，实际识别 %d 次", logins)
+		}
+		first.Close()
+
+		info, err := os.Stat(c.SessionFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Fatalf("会话文件的权限应该为 0600，实际为 %o", info.Mode().Perm())
+		}
+		data, _ := ioutil.ReadFile(c.SessionFile)
+		if encrypted := !strings.Contains(string(data), testAccount); encrypted != (key != "") {
+			t.Fatalf("SessionKey=%q 时会话文件加密状态错误", key)
+		}
+
+		second, logins := loginWithSession(t, s, c)
+		if logins != 0 || s.SessionCount() != 1 {
+			t.Fatalf("应该复用保存的会话，实际识别 %d 次，会话数 %d", logins, s.SessionCount())
+		}
+		if _, err := second.GetOrdersList(); err != nil {
+			t.Fatalf("复用的会话查询失败: %v", err)
+		}
+	}
+}
+
+func TestSessionRestoreFallback(t *testing.T) {
+	s := newSessionBroker(t)
+	c := client.EastMoneyClientConfig{SessionFile: filepath.Join(t.TempDir(), "session.json"), SessionKey: "secret"}
+	first, _ := loginWithSession(t, s, c)
+	first.Close()
+
+	// 密钥错误、会话在券商处失效时都重新登录
+	wrongKey := c
+	wrongKey.SessionKey = "other"
+	if _, logins := loginWithSession(t, s, wrongKey); logins != 1 {
+		t.Fatalf("密钥错误时应该重新登录，实际识别 %d 次", logins)
+	}
+	s.ExpireSessions()
+	if _, logins := loginWithSession(t, s, c); logins != 1 {
+		t.Fatalf("会话失效时应该重新登录，实际识别 %d 次", logins)
+	}
+}
+
+func TestSessionClearedOnLogout(t *testing.T) {
+	s := newSessionBroker(t)
+	c := client.EastMoneyClientConfig{SessionFile: filepath.Join(t.TempDir(), "session.json"), LogoutOnClose: true}
+	e, _ := loginWithSession(t, s, c)
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.SessionFile); !os.IsNotExist(err) {
+		t.Fatal("退出登录后应该删除会话文件")
+	}
+}
+
+func TestFileSessionStoreEmpty(t *testing.T) {
+	dir := t.TempDir()
+	stores := []client.SessionStore{
+		&client.FileSessionStore{Path: filepath.Join(dir, "plain.json")},
+		&client.EncryptedFileSessionStore{Path: filepath.Join(dir, "encrypted.bin"), Key: "secret"},
+	}
+	for _, store := range stores {
+		if s, err := store.Load(); s != nil || err != nil {
+			t.Fatalf("没有保存过会话时应该返回 nil, nil，实际为 %v %v", s, err)
+		}
+		if err := store.Clear(); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Save(&client.Session{Account: testAccount, ValidateKey: "key"}); err != nil {
+			t.Fatal(err)
+		}
+		if s, err := store.Load(); err != nil || s.Account != testAccount || s.ValidateKey != "key" {
+			t.Fatalf("读取的会话错误: %+v %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestIsSessionExpired(t *testing.T) {
+	cases := []struct {
+		path string
+		body string
+		want bool
+	}{
+		{"/Login", "<html></html>", true},
+		{"/Search/GetOrdersData", `{"Status":-2,"Errcode":0,"Message":""}`, true},
+		{"/Search/GetOrdersData", `{"Status":"-1","Errcode":"-2","Message":"unknown"}`, true},
+		{"/Search/GetOrdersData", `{"Status":-1,"Errcode":-1,"Message":"会话已超时，请重新登录!"}`, true},
+		{"/Search/GetOrdersData", `{"Status":-1,"Errcode":-1,"Message":"系统繁忙，请稍后再试"}`, false},
+		{"/Search/GetOrdersData", `{"Status":0,"Message":"","Data":[]}`, false},
+		{"/Search/GetOrdersData", `not json`, false},
+	}
+	for _, c := range cases {
+		resp := &http.Response{
+			Request: &http.Request{URL: &url.URL{Path: c.path}},
+			Body:    ioutil.NopCloser(strings.NewReader(c.body)),
+		}
+		if got := isSessionExpired(resp); got != c.want {
+			t.Errorf("%s %s: isSessionExpired = %v，应该为 %v", c.path, c.body, got, c.want)
+		}
+		// 判断后 Body 仍然可以读取
+		if body, _ := ioutil.ReadAll(resp.Body); string(body) != c.body {
+			t.Errorf("%s: Body 被消耗", c.body)
+		}
+	}
+}
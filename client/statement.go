@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+)
+
+// GetFundFlow 查询 [begin, end] 期间的资金流水，只比较日期部分
+func (e *EastMoneyClient) GetFundFlow(begin, end time.Time) (model.FundFlows, error) {
+	return e.GetFundFlowContext(context.Background(), begin, end)
+}
+
+// GetFundFlowContext 查询 [begin, end] 期间的资金流水，只比较日期部分
+func (e *EastMoneyClient) GetFundFlowContext(ctx context.Context, begin, end time.Time) (model.FundFlows, error) {
+	var result model.FundFlows
+	err := e.eachHistoryPage(ctx, "/Search/GetFundsFlow", begin, end, func(data json.RawMessage) error {
+		var page model.FundFlows
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		result = append(result, page...)
+		return nil
+	})
+	return result, err
+}
+
+// GetDeliveryStatement 查询 [begin, end] 期间的交割单，只比较日期部分
+func (e *EastMoneyClient) GetDeliveryStatement(begin, end time.Time) ([]*model.DeliveryStatement, error) {
+	return e.GetDeliveryStatementContext(context.Background(), begin, end)
+}
+
+// GetDeliveryStatementContext 查询 [begin, end] 期间的交割单，只比较日期部分
+func (e *EastMoneyClient) GetDeliveryStatementContext(ctx context.Context, begin, end time.Time) ([]*model.DeliveryStatement, error) {
+	var result []*model.DeliveryStatement
+	err := e.eachHistoryPage(ctx, "/Search/GetDeliveryData", begin, end, func(data json.RawMessage) error {
+		var page []*model.DeliveryStatement
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		result = append(result, page...)
+		return nil
+	})
+	return result, err
+}
+
+// ReconcileFunds 用 [begin, 今天] 的资金流水的期末余额与 QueryAssetAndPosition 返回的资金余额对账，今天按北京时间计算
+func (e *EastMoneyClient) ReconcileFunds(begin time.Time) (*model.CashReconciliation, error) {
+	return e.ReconcileFundsContext(context.Background(), begin)
+}
+
+// ReconcileFundsContext 用 [begin, 今天] 的资金流水的期末余额与 QueryAssetAndPosition 返回的资金余额对账
+func (e *EastMoneyClient) ReconcileFundsContext(ctx context.Context, begin time.Time) (*model.CashReconciliation, error) {
+	flows, err := e.GetFundFlowContext(ctx, begin, time.Now().In(cst))
+	if err != nil {
+		return nil, err
+	}
+	account, err := e.QueryAssetAndPositionContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := flows.Reconcile(account)
+	return &result, nil
+}
@@ -0,0 +1,63 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestFundFlowAndReconcile(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{PageSize: 2})
+	if err := s.AddPosition(testAccount, "159915", "创业板ETF", 1000, decimal.RequireFromString("2.300")); err != nil {
+		t.Fatal(err)
+	}
+	buy, err := e.SubmitTrade(buyForm(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fill(buy, 400, decimal.RequireFromString("3.850")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fill(buy, 600, decimal.RequireFromString("3.856")); err != nil {
+		t.Fatal(err)
+	}
+	sell := s.AddOrder(fakebroker.Order{Account: testAccount, Code: "159915", Name: "创业板ETF", TradeType: "S", Price: decimal.RequireFromString("2.400"), Amount: 500})
+	if err := s.Fill(sell, 500, decimal.RequireFromString("2.400")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不管服务器在哪个时区，今天都按北京时间计算，包含今天的流水
+	today := time.Now().In(time.FixedZone("CST", 8*3600))
+	begin := today.AddDate(0, 0, -3)
+	flows, err := e.GetFundFlow(begin, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 银行转证券 + 两笔买入成交 + 一笔卖出成交
+	if len(flows) != 4 {
+		t.Fatalf("应该有4笔资金流水，实际 %d 笔", len(flows))
+	}
+	if !flows.NetAmount().Equal(decimal.RequireFromString("1000000").Sub(decimal.RequireFromString("3853.60")).Add(decimal.RequireFromString("1200"))) {
+		t.Fatalf("发生金额合计错误: %s", flows.NetAmount())
+	}
+
+	statements, err := e.GetDeliveryStatement(begin, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 3 || statements[0].OrderId != buy || statements[0].Quantity != 400 || !statements[0].Price.Equal(decimal.RequireFromString("3.85")) {
+		t.Fatalf("交割单错误: %+v", statements)
+	}
+
+	r, err := e.ReconcileFunds(begin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Difference.IsZero() || !r.StatementBalance.Equal(flows.ClosingBalance()) {
+		t.Fatalf("对账结果错误: %+v", r)
+	}
+}
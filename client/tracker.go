@@ -0,0 +1,306 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/shopspring/decimal"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// 默认的查询间隔
+const defaultTrackInterval = 2 * time.Second
+
+// OrderEventType 委托事件的类型
+type OrderEventType string
+
+const (
+	// OrderEventSubmitted 委托第一次出现在当日委托中
+	OrderEventSubmitted OrderEventType = "submitted"
+	// OrderEventPartiallyFilled 有新的成交，委托还没有全部成交
+	OrderEventPartiallyFilled OrderEventType = "partially_filled"
+	// OrderEventFilled 全部成交
+	OrderEventFilled OrderEventType = "filled"
+	// OrderEventCancelled 已撤或部撤
+	OrderEventCancelled OrderEventType = "cancelled"
+	// OrderEventRejected 废单
+	OrderEventRejected OrderEventType = "rejected"
+)
+
+// OrderEvent 委托状态变化的事件
+type OrderEvent struct {
+	Type          OrderEventType
+	OrderId       string
+	ClientOrderId string
+	Code          string
+	TradeType     model.TradeType
+	Status        model.OrderStatus
+	Price         decimal.Decimal // 委托价格
+	Amount        int             // 委托数量
+	FilledAmount  int             // 累计成交数量
+	AvgFillPrice  decimal.Decimal // 成交均价，没有成交时为0
+	// 本次新增的成交数量和成交均价，只有成交事件才有
+	LastFillAmount int
+	LastFillPrice  decimal.Decimal
+	Time           time.Time
+}
+
+// trackedOrder 跟踪中的委托上一次的状态
+type trackedOrder struct {
+	seen         bool
+	status       model.OrderStatus
+	filledAmount int
+	filledValue  decimal.Decimal
+}
+
+// eventCallback OnEvent 注册的回调，id 用于取消注册
+type eventCallback struct {
+	id int
+	fn func(OrderEvent)
+}
+
+// OrderTracker 定时查询当日委托和当日成交，计算跟踪中的委托的状态变化并发布 OrderEvent。
+// 事件可以通过 Subscribe 返回的 channel 或者 OnEvent 注册的回调接收，委托结束（全部成交、撤单、废单）后自动停止跟踪
+//
+//	t := client.NewOrderTracker(c, time.Second)
+//	events := t.Subscribe(100)
+//	t.Start(ctx)
+//	defer t.Stop()
+//	t.Track(orderId)
+type OrderTracker struct {
+	e        *EastMoneyClient
+	interval time.Duration
+
+	mu          sync.Mutex
+	orders      map[string]*trackedOrder
+	callbacks   []eventCallback
+	nextId      int
+	subscribers []chan OrderEvent
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewOrderTracker 创建委托跟踪器，interval 为查询间隔，为0时默认2秒
+func NewOrderTracker(e *EastMoneyClient, interval time.Duration) *OrderTracker {
+	if interval <= 0 {
+		interval = defaultTrackInterval
+	}
+	return &OrderTracker{
+		e:        e,
+		interval: interval,
+		orders:   make(map[string]*trackedOrder),
+	}
+}
+
+// Track 开始跟踪委托
+func (t *OrderTracker) Track(orderIds ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, id := range orderIds {
+		if _, ok := t.orders[id]; !ok {
+			t.orders[id] = &trackedOrder{}
+		}
+	}
+}
+
+// Untrack 停止跟踪委托
+func (t *OrderTracker) Untrack(orderId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.orders, orderId)
+}
+
+// Tracking 跟踪中的委托数量
+func (t *OrderTracker) Tracking() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.orders)
+}
+
+// OnEvent 注册回调，回调在查询的协程中依次调用，不应该阻塞。
+// 返回的函数用于取消注册，可以重复调用；正在进行的查询仍然可能调用一次已取消的回调
+func (t *OrderTracker) OnEvent(fn func(OrderEvent)) (remove func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextId++
+	id := t.nextId
+	t.callbacks = append(t.callbacks, eventCallback{id: id, fn: fn})
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for i, cb := range t.callbacks {
+			if cb.id == id {
+				t.callbacks = append(t.callbacks[:i:i], t.callbacks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Subscribe 订阅事件，buffer 为 channel 的容量。channel 满了之后查询的协程会等待，不会丢弃事件，
+// 因此订阅后需要持续读取。Stop 之后 channel 会被关闭
+func (t *OrderTracker) Subscribe(buffer int) <-chan OrderEvent {
+	ch := make(chan OrderEvent, buffer)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subscribers = append(t.subscribers, ch)
+	return ch
+}
+
+// Start 在协程中定时查询，直到 ctx 被取消或者调用 Stop
+func (t *OrderTracker) Start(ctx context.Context) {
+	ctx, t.cancel = context.WithCancel(ctx)
+	t.done = make(chan struct{})
+	go t.run(ctx)
+}
+
+// Stop 停止查询，等待查询的协程退出后关闭所有订阅的 channel
+func (t *OrderTracker) Stop() {
+	if t.cancel == nil {
+		return
+	}
+	t.cancel()
+	<-t.done
+}
+
+func (t *OrderTracker) run(ctx context.Context) {
+	defer func() {
+		t.mu.Lock()
+		for _, ch := range t.subscribers {
+			close(ch)
+		}
+		t.subscribers = nil
+		t.mu.Unlock()
+		close(t.done)
+	}()
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		if err := t.Poll(ctx); err != nil && ctx.Err() == nil {
+			logrus.Warnf("账号 %s 查询委托状态失败: %s", t.e.config.Account, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll 查询一次当日委托和当日成交，发布跟踪中的委托的状态变化。没有跟踪的委托时不查询
+func (t *OrderTracker) Poll(ctx context.Context) error {
+	if t.Tracking() == 0 {
+		return nil
+	}
+	orders, err := t.e.GetOrdersListContext(ctx)
+	if err != nil {
+		return err
+	}
+	deals, err := t.e.GetDealListContext(ctx)
+	if err != nil {
+		return err
+	}
+	fills := make(map[string]*trackedOrder)
+	for _, d := range deals {
+		f, ok := fills[d.OrderId]
+		if !ok {
+			f = &trackedOrder{}
+			fills[d.OrderId] = f
+		}
+		f.filledAmount += d.ClosingAmount()
+		f.filledValue = f.filledValue.Add(d.ClosingPrice().Mul(decimal.NewFromInt(int64(d.ClosingAmount()))))
+	}
+
+	var events []OrderEvent
+	t.mu.Lock()
+	for _, o := range orders {
+		state, ok := t.orders[o.OrderId]
+		if !ok {
+			continue
+		}
+		filledAmount, filledValue := o.ClosingAmount(), o.ClosingPrice().Mul(decimal.NewFromInt(int64(o.ClosingAmount())))
+		// 成交明细比委托的成交数量更新时以成交明细为准
+		if f, ok := fills[o.OrderId]; ok && f.filledAmount >= filledAmount {
+			filledAmount, filledValue = f.filledAmount, f.filledValue
+		}
+		events = append(events, t.diff(o, state, filledAmount, filledValue)...)
+		// 已成但成交还没有全部查询到时继续跟踪，以免漏掉全部成交的事件
+		if status := o.OrderStatus(); status.IsFinal() && (status != model.OrderStatusFilled || filledAmount >= o.Amount()) {
+			delete(t.orders, o.OrderId)
+		}
+	}
+	callbacks := make([]func(OrderEvent), 0, len(t.callbacks))
+	for _, cb := range t.callbacks {
+		callbacks = append(callbacks, cb.fn)
+	}
+	subscribers := append([]chan OrderEvent{}, t.subscribers...)
+	t.mu.Unlock()
+
+	for _, ev := range events {
+		for _, fn := range callbacks {
+			fn(ev)
+		}
+		for _, ch := range subscribers {
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// diff 根据委托最新的状态和成交生成事件，并更新 state，调用方需要持有 mu
+func (t *OrderTracker) diff(o *model.Order, state *trackedOrder, filledAmount int, filledValue decimal.Decimal) []OrderEvent {
+	newEvent := func(typ OrderEventType) OrderEvent {
+		ev := OrderEvent{
+			Type:          typ,
+			OrderId:       o.OrderId,
+			ClientOrderId: t.e.ClientOrderId(o.OrderId),
+			Code:          o.Code,
+			TradeType:     o.TradeType(),
+			Status:        o.OrderStatus(),
+			Price:         o.OrderPrice(),
+			Amount:        o.Amount(),
+			FilledAmount:  filledAmount,
+			Time:          time.Now(),
+		}
+		if filledAmount > 0 {
+			ev.AvgFillPrice = filledValue.Div(decimal.NewFromInt(int64(filledAmount)))
+		}
+		return ev
+	}
+
+	var events []OrderEvent
+	if !state.seen {
+		state.seen = true
+		events = append(events, newEvent(OrderEventSubmitted))
+	}
+	if filledAmount > state.filledAmount {
+		typ := OrderEventPartiallyFilled
+		if filledAmount >= o.Amount() {
+			typ = OrderEventFilled
+		}
+		ev := newEvent(typ)
+		ev.LastFillAmount = filledAmount - state.filledAmount
+		ev.LastFillPrice = filledValue.Sub(state.filledValue).Div(decimal.NewFromInt(int64(ev.LastFillAmount)))
+		events = append(events, ev)
+		state.filledAmount, state.filledValue = filledAmount, filledValue
+	}
+	status := o.OrderStatus()
+	if status != state.status {
+		switch status {
+		case model.OrderStatusRevoked, model.OrderStatusPartFillRevoked:
+			events = append(events, newEvent(OrderEventCancelled))
+		case model.OrderStatusRejected:
+			events = append(events, newEvent(OrderEventRejected))
+		}
+		state.status = status
+	}
+	return events
+}
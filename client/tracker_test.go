@@ -0,0 +1,175 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// pollEvents 查询一次，返回本次发布的事件
+func pollEvents(t *testing.T, tracker *client.OrderTracker) []client.OrderEvent {
+	t.Helper()
+	var events []client.OrderEvent
+	remove := tracker.OnEvent(func(ev client.OrderEvent) { events = append(events, ev) })
+	defer remove()
+	if err := tracker.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// expectEvent 检查只发布了一个指定类型的事件
+func expectEvent(t *testing.T, events []client.OrderEvent, typ client.OrderEventType) client.OrderEvent {
+	t.Helper()
+	if len(events) != 1 || events[0].Type != typ {
+		t.Fatalf("应该只发布一个 %s 事件，实际为 %+v", typ, events)
+	}
+	return events[0]
+}
+
+func TestTrackerFills(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+	tracker := client.NewOrderTracker(e, 0)
+	form := buyForm(1000)
+	form.ClientOrderId = "tracker-fill"
+	orderId, err := e.SubmitTrade(form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Track(orderId)
+	ev := expectEvent(t, pollEvents(t, tracker), client.OrderEventSubmitted)
+	if ev.OrderId != orderId || ev.ClientOrderId != "tracker-fill" || ev.Amount != 1000 || ev.TradeType != model.TradeTypeBuy {
+		t.Fatalf("委托事件错误: %+v", ev)
+	}
+	if events := pollEvents(t, tracker); len(events) != 0 {
+		t.Fatalf("状态没有变化时不应该发布事件: %+v", events)
+	}
+
+	if err := s.Fill(orderId, 300, decimal.RequireFromString("3.850")); err != nil {
+		t.Fatal(err)
+	}
+	ev = expectEvent(t, pollEvents(t, tracker), client.OrderEventPartiallyFilled)
+	if ev.FilledAmount != 300 || ev.LastFillAmount != 300 || !ev.LastFillPrice.Equal(decimal.RequireFromString("3.85")) {
+		t.Fatalf("部分成交事件错误: %+v", ev)
+	}
+	if events := pollEvents(t, tracker); len(events) != 0 {
+		t.Fatalf("部分成交事件重复发布: %+v", events)
+	}
+
+	if err := s.Fill(orderId, 700, decimal.RequireFromString("3.856")); err != nil {
+		t.Fatal(err)
+	}
+	ev = expectEvent(t, pollEvents(t, tracker), client.OrderEventFilled)
+	if ev.FilledAmount != 1000 || ev.LastFillAmount != 700 || !ev.LastFillPrice.Equal(decimal.RequireFromString("3.856")) {
+		t.Fatalf("全部成交事件错误: %+v", ev)
+	}
+	// (300 * 3.850 + 700 * 3.856) / 1000
+	if !ev.AvgFillPrice.Equal(decimal.RequireFromString("3.8542")) {
+		t.Fatalf("成交均价为 %s，应该为 3.8542", ev.AvgFillPrice)
+	}
+	if tracker.Tracking() != 0 {
+		t.Fatal("全部成交后应该停止跟踪")
+	}
+	if events := pollEvents(t, tracker); len(events) != 0 {
+		t.Fatalf("全部成交事件重复发布: %+v", events)
+	}
+}
+
+func TestTrackerRejected(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+	tracker := client.NewOrderTracker(e, 0)
+	orderId, err := e.SubmitTrade(buyForm(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Track(orderId)
+	expectEvent(t, pollEvents(t, tracker), client.OrderEventSubmitted)
+	if err := s.Reject(orderId); err != nil {
+		t.Fatal(err)
+	}
+	ev := expectEvent(t, pollEvents(t, tracker), client.OrderEventRejected)
+	if ev.Status != model.OrderStatusRejected {
+		t.Fatalf("废单事件的状态错误: %+v", ev)
+	}
+	if tracker.Tracking() != 0 {
+		t.Fatal("废单后应该停止跟踪")
+	}
+	if events := pollEvents(t, tracker); len(events) != 0 {
+		t.Fatalf("废单事件重复发布: %+v", events)
+	}
+}
+
+func TestTrackerRevoked(t *testing.T) {
+	s, e := newTestClient(t, client.EastMoneyClientConfig{})
+	tracker := client.NewOrderTracker(e, time.Millisecond)
+	events := tracker.Subscribe(10)
+	orderId, err := e.SubmitTrade(buyForm(500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fill(orderId, 200, decimal.RequireFromString("3.856")); err != nil {
+		t.Fatal(err)
+	}
+	tracker.Track(orderId)
+	if err := tracker.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.RevokeByOrderId(orderId); err != nil {
+		t.Fatal(err)
+	}
+	// 在协程中查询，撤单后停止跟踪，Stop 之后 channel 被关闭
+	tracker.Start(context.Background())
+	deadline := time.Now().Add(time.Second)
+	for tracker.Tracking() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	tracker.Stop()
+
+	var types []client.OrderEventType
+	var cancelled client.OrderEvent
+	for ev := range events {
+		types = append(types, ev.Type)
+		if ev.Type == client.OrderEventCancelled {
+			cancelled = ev
+		}
+	}
+	want := []client.OrderEventType{client.OrderEventSubmitted, client.OrderEventPartiallyFilled, client.OrderEventCancelled}
+	if len(types) != len(want) {
+		t.Fatalf("事件应该为 %v，实际为 %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("事件应该为 %v，实际为 %v", want, types)
+		}
+	}
+	if cancelled.Status != model.OrderStatusPartFillRevoked || cancelled.FilledAmount != 200 {
+		t.Fatalf("撤单事件错误: %+v", cancelled)
+	}
+}
+
+func TestTrackerRemoveCallback(t *testing.T) {
+	_, e := newTestClient(t, client.EastMoneyClientConfig{})
+	tracker := client.NewOrderTracker(e, 0)
+	var kept, removed int
+	tracker.OnEvent(func(client.OrderEvent) { kept++ })
+	remove := tracker.OnEvent(func(client.OrderEvent) { removed++ })
+	remove()
+	remove()
+
+	orderId, err := e.SubmitTrade(buyForm(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Track(orderId)
+	if err := tracker.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if kept != 1 || removed != 0 {
+		t.Fatalf("取消注册的回调不应该被调用: kept=%d removed=%d", kept, removed)
+	}
+}
@@ -30,7 +30,10 @@ func main() {
 	// z := service.NewZ513050Svc()
 	// z.Start()
 
-	c := client.NewEastMoneyClient(config.GetConfig().EastMoneyClientConfig)
+	c, err := client.NewEastMoneyClient(config.GetConfig().EastMoneyClientConfig)
+	if err != nil {
+		panic(err)
+	}
 
 	new, err := c.GetCanBuyNewStockList()
 	if err != nil {
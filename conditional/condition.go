@@ -0,0 +1,184 @@
+package conditional
+
+import (
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+	"github.com/yfjiang-danny/eastmoneyapi/risk"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Kind 条件单的类型
+type Kind string
+
+const (
+	// StopLoss 止损：卖出时最新价跌到 TriggerPrice 及以下触发，买入时涨到 TriggerPrice 及以上触发
+	StopLoss Kind = "stop_loss"
+	// TakeProfit 止盈：卖出时最新价涨到 TriggerPrice 及以上触发，买入时跌到 TriggerPrice 及以下触发
+	TakeProfit Kind = "take_profit"
+	// TrailingStop 跟踪止损：卖出时从最高价回落 TrailPercent 或 TrailAmount 触发，买入时从最低价反弹触发
+	TrailingStop Kind = "trailing_stop"
+)
+
+// Status 条件单的状态
+type Status string
+
+const (
+	// StatusPending 等待触发
+	StatusPending Status = "pending"
+	// StatusTriggered 已触发并提交了委托，委托编号为 OrderId；OrderId 为空时正在提交或者委托结果未知（原因见 Error）
+	StatusTriggered Status = "triggered"
+	// StatusCancelled 已取消，或者同一 OCO 组的其他条件单已触发
+	StatusCancelled Status = "cancelled"
+	// StatusFailed 已触发但委托提交失败，原因见 Error
+	StatusFailed Status = "failed"
+)
+
+// Condition 条件单
+type Condition struct {
+	Id        string
+	Kind      Kind
+	Code      string
+	Name      string
+	TradeType model.TradeType
+	Amount    int
+
+	// 止损、止盈的触发价格
+	TriggerPrice decimal.Decimal
+	// 跟踪止损的回落比例（0.05 表示 5%）和回落金额，二选一
+	TrailPercent decimal.Decimal
+	TrailAmount  decimal.Decimal
+	// 跟踪止损触发前的最高价（卖出）或最低价（买入），为0时使用添加后的第一个最新价
+	Extreme decimal.Decimal
+
+	// 委托价格在对手价（卖出为买1价，买入为卖1价）的基础上让出的最小变动单位个数，保证尽快成交
+	SlippageTicks int
+	// OCO 组，同一组的条件单有一个触发并且委托提交成功后，其余的自动取消
+	OcoGroup string
+
+	Status      Status
+	OrderId     string
+	Error       string
+	CreatedAt   time.Time
+	TriggeredAt time.Time
+}
+
+// awaitingOrder 已触发但还没有委托编号：正在提交或者委托结果未知
+func (c *Condition) awaitingOrder() bool {
+	return c.Status == StatusTriggered && c.OrderId == ""
+}
+
+func (c *Condition) validate() error {
+	if c.Code == "" {
+		return errors.New("证券代码为空")
+	}
+	if c.Amount <= 0 {
+		return errors.New("委托数量必须大于0")
+	}
+	if c.TradeType != model.TradeTypeBuy && c.TradeType != model.TradeTypeSale {
+		return errors.New("委托方向错误")
+	}
+	switch c.Kind {
+	case StopLoss, TakeProfit:
+		if !c.TriggerPrice.IsPositive() {
+			return errors.New("触发价格必须大于0")
+		}
+	case TrailingStop:
+		if c.TrailPercent.IsPositive() == c.TrailAmount.IsPositive() {
+			return errors.New("跟踪止损需要设置 TrailPercent 或 TrailAmount 其中之一")
+		}
+		if c.TrailPercent.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+			return errors.New("TrailPercent 必须小于1")
+		}
+	default:
+		return errors.Errorf("未知的条件单类型: %s", c.Kind)
+	}
+	return nil
+}
+
+// StopPrice 跟踪止损当前的触发价格，Extreme 为0时返回0
+func (c *Condition) StopPrice() decimal.Decimal {
+	if c.Kind != TrailingStop || c.Extreme.IsZero() {
+		return c.TriggerPrice
+	}
+	sell := c.TradeType == model.TradeTypeSale
+	if c.TrailAmount.IsPositive() {
+		if sell {
+			return c.Extreme.Sub(c.TrailAmount)
+		}
+		return c.Extreme.Add(c.TrailAmount)
+	}
+	one := decimal.NewFromInt(1)
+	if sell {
+		return c.Extreme.Mul(one.Sub(c.TrailPercent))
+	}
+	return c.Extreme.Mul(one.Add(c.TrailPercent))
+}
+
+// update 根据最新价更新跟踪止损的最高价/最低价，返回是否触发以及 Extreme 是否发生了变化
+func (c *Condition) update(last decimal.Decimal) (triggered, changed bool) {
+	sell := c.TradeType == model.TradeTypeSale
+	switch c.Kind {
+	case StopLoss:
+		if sell {
+			return last.LessThanOrEqual(c.TriggerPrice), false
+		}
+		return last.GreaterThanOrEqual(c.TriggerPrice), false
+	case TakeProfit:
+		if sell {
+			return last.GreaterThanOrEqual(c.TriggerPrice), false
+		}
+		return last.LessThanOrEqual(c.TriggerPrice), false
+	}
+
+	if c.Extreme.IsZero() || (sell && last.GreaterThan(c.Extreme)) || (!sell && last.LessThan(c.Extreme)) {
+		c.Extreme = last
+		changed = true
+	}
+	stop := c.StopPrice()
+	if sell {
+		return last.LessThanOrEqual(stop), changed
+	}
+	return last.GreaterThanOrEqual(stop), changed
+}
+
+// orderPrice 触发后的委托价格：卖出为买1价减去让价，买入为卖1价加上让价，没有对手价时使用最新价。
+// 价格不超出涨跌停价格
+func (c *Condition) orderPrice(q *model.Stockquote) decimal.Decimal {
+	tick := risk.PriceTick(c.Code)
+	slippage := tick.Mul(decimal.NewFromInt(int64(c.SlippageTicks)))
+	last := decimalFromFloat(q.NewestPrice)
+	var price decimal.Decimal
+	if c.TradeType == model.TradeTypeSale {
+		price = decimalFromFloat(q.BuyPrice1)
+		if !price.IsPositive() {
+			price = last
+		}
+		price = price.Sub(slippage)
+	} else {
+		price = decimalFromFloat(q.SalePrice1)
+		if !price.IsPositive() {
+			price = last
+		}
+		price = price.Add(slippage)
+	}
+	price = price.Round(-tick.Exponent())
+
+	if preClose := decimalFromFloat(q.PreClosePrice); preClose.IsPositive() {
+		down, up := risk.PriceLimits(preClose, risk.PriceLimitRatio(c.Code, q.Name), tick)
+		if price.GreaterThan(up) {
+			price = up
+		}
+		if price.LessThan(down) {
+			price = down
+		}
+	}
+	return price
+}
+
+// decimalFromFloat 行情的价格是 float64，转换时去掉浮点误差
+func decimalFromFloat(f float64) decimal.Decimal {
+	return decimal.NewFromFloat(f).Round(3)
+}
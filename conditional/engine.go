@@ -0,0 +1,392 @@
+// Package conditional 本地条件单：止损、止盈、跟踪止损以及 OCO（二选一）。
+// 东财网页版只支持普通的限价委托，条件单在本地定时查询行情，触发后以对手价提交限价委托
+package conditional
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/api"
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// 默认的行情查询间隔
+const defaultInterval = 3 * time.Second
+
+// Submitter 提交委托，*client.EastMoneyClient 实现了该接口
+type Submitter interface {
+	SubmitTradeContext(ctx context.Context, order model.TradeOrderForm) (string, error)
+}
+
+// QuoteFunc 查询证券的最新行情
+type QuoteFunc func(ctx context.Context, code string) (*model.Stockquote, error)
+
+// Config 条件单引擎的配置
+type Config struct {
+	// 行情查询间隔，默认3秒
+	Interval time.Duration
+	// 条件单的存储，为空时只保存在内存中
+	Store Store
+	// 查询行情，默认为 api.GetQuoteContext
+	Quote QuoteFunc
+}
+
+// Engine 条件单引擎
+type Engine struct {
+	submitter Submitter
+	config    Config
+
+	mu         sync.Mutex
+	conditions map[string]*Condition
+	callbacks  []func(Condition)
+	// 正在提交委托的条件单
+	submitting map[string]bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewEngine 创建条件单引擎，并从 Store 中恢复等待触发的条件单
+func NewEngine(submitter Submitter, c Config) (*Engine, error) {
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.Quote == nil {
+		c.Quote = api.GetQuoteContext
+	}
+	e := &Engine{
+		submitter:  submitter,
+		config:     c,
+		conditions: make(map[string]*Condition),
+		submitting: make(map[string]bool),
+	}
+	if c.Store != nil {
+		conditions, err := c.Store.Load()
+		if err != nil {
+			return nil, errors.Wrap(err, "读取条件单失败")
+		}
+		for _, cond := range conditions {
+			// 上次退出时已触发但没有拿到委托编号，本地的客户端委托记录已经丢失，无法在当日委托中可靠地查找，不再自动提交
+			if cond.awaitingOrder() {
+				cond.Status = StatusFailed
+				cond.Error = "退出前已触发，委托结果未知，请检查当日委托"
+				logrus.Warnf("条件单 %s(%s %s) %s", cond.Id, cond.Kind, cond.Code, cond.Error)
+			}
+			e.conditions[cond.Id] = cond
+		}
+	}
+	return e, nil
+}
+
+// Add 添加条件单，返回条件单编号
+func (e *Engine) Add(c Condition) (string, error) {
+	ids, err := e.add(c)
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// AddOCO 添加一组 OCO 条件单（如同一持仓的止损和止盈），其中一个触发并且委托提交成功后其余的自动取消，
+// 提交失败时其余的继续等待触发
+func (e *Engine) AddOCO(conditions ...Condition) ([]string, error) {
+	if len(conditions) < 2 {
+		return nil, errors.New("OCO 至少需要两个条件单")
+	}
+	group := newId()
+	for i := range conditions {
+		conditions[i].OcoGroup = group
+	}
+	return e.add(conditions...)
+}
+
+func (e *Engine) add(conditions ...Condition) ([]string, error) {
+	for i := range conditions {
+		if err := conditions[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ids := make([]string, 0, len(conditions))
+	for _, c := range conditions {
+		c := c
+		if c.Id == "" {
+			c.Id = newId()
+		}
+		if _, ok := e.conditions[c.Id]; ok {
+			return nil, errors.Errorf("条件单编号重复: %s", c.Id)
+		}
+		c.Status = StatusPending
+		c.CreatedAt = time.Now()
+		e.conditions[c.Id] = &c
+		ids = append(ids, c.Id)
+	}
+	return ids, e.saveLocked()
+}
+
+// Cancel 取消等待触发的条件单
+func (e *Engine) Cancel(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, ok := e.conditions[id]
+	if !ok || c.Status != StatusPending {
+		return errors.Errorf("没有等待触发的条件单: %s", id)
+	}
+	c.Status = StatusCancelled
+	e.notifyLocked(*c)
+	return e.saveLocked()
+}
+
+// Get 查询条件单
+func (e *Engine) Get(id string) (Condition, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, ok := e.conditions[id]
+	if !ok {
+		return Condition{}, false
+	}
+	return *c, true
+}
+
+// Conditions 所有条件单，按添加时间排序
+func (e *Engine) Conditions() []Condition {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := make([]Condition, 0, len(e.conditions))
+	for _, c := range e.conditions {
+		list = append(list, *c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// OnEvent 注册回调，条件单触发、取消或者失败时调用，回调中不能再调用 Engine 的方法
+func (e *Engine) OnEvent(fn func(Condition)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.callbacks = append(e.callbacks, fn)
+}
+
+// Start 在协程中定时检查条件单，直到 ctx 被取消或者调用 Stop
+func (e *Engine) Start(ctx context.Context) {
+	ctx, e.cancel = context.WithCancel(ctx)
+	e.done = make(chan struct{})
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.config.Interval)
+		defer ticker.Stop()
+		for {
+			if err := e.Poll(ctx); err != nil && ctx.Err() == nil {
+				logrus.Warnf("检查条件单失败: %s", err.Error())
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止检查并等待协程退出
+func (e *Engine) Stop() {
+	if e.cancel == nil {
+		return
+	}
+	e.cancel()
+	<-e.done
+}
+
+// Poll 查询一次行情并检查所有等待触发的条件单，同一证券只查询一次行情；
+// 已触发但委托结果未知的条件单使用相同的客户端委托编号重新提交（先在当日委托中查找，不会重复委托）。
+// 查询某个证券的行情失败时跳过该证券，返回最后一个错误
+func (e *Engine) Poll(ctx context.Context) error {
+	codes := e.pendingCodes()
+	var lastErr error
+	for _, code := range codes {
+		quote, err := e.config.Quote(ctx, code)
+		if err != nil {
+			lastErr = errors.Wrapf(err, "查询 %s 行情失败", code)
+			continue
+		}
+		if err := e.check(ctx, code, quote); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (e *Engine) pendingCodes() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	set := make(map[string]bool)
+	for _, c := range e.conditions {
+		if c.Status == StatusPending || c.awaitingOrder() {
+			set[c.Code] = true
+		}
+	}
+	codes := make([]string, 0, len(set))
+	for code := range set {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// check 使用最新行情检查该证券的条件单，触发的条件单提交委托
+func (e *Engine) check(ctx context.Context, code string, quote *model.Stockquote) error {
+	last := decimalFromFloat(quote.NewestPrice)
+	if !last.IsPositive() {
+		// 停牌或者还没有成交
+		return nil
+	}
+
+	e.mu.Lock()
+	var triggered []*Condition
+	changed := false
+	for _, c := range e.sortedLocked() {
+		if c.Code != code || e.submitting[c.Id] {
+			continue
+		}
+		if c.awaitingOrder() {
+			// 上次提交的结果未知，同一个 OCO 组的其他条件单暂停触发，直到查找到委托或者确定提交失败
+			e.submitting[c.Id] = true
+			triggered = append(triggered, c)
+			continue
+		}
+		if c.Status != StatusPending {
+			continue
+		}
+		fire, moved := c.update(last)
+		if fire && e.submittingLocked(c) {
+			// 同一个 OCO 组的其他条件单正在提交委托，等待提交结果
+			fire = false
+		}
+		changed = changed || moved
+		if !fire {
+			continue
+		}
+		// 先标记为已触发并保存，避免提交委托期间进程退出导致重启后重复触发
+		c.Status = StatusTriggered
+		c.TriggeredAt = time.Now()
+		e.submitting[c.Id] = true
+		triggered = append(triggered, c)
+		changed = true
+	}
+	var err error
+	if changed {
+		err = e.saveLocked()
+	}
+	e.mu.Unlock()
+
+	for _, c := range triggered {
+		order := model.TradeOrderForm{
+			Code:          c.Code,
+			Name:          c.Name,
+			Price:         c.orderPrice(quote),
+			Amount:        c.Amount,
+			TradeType:     c.TradeType,
+			ClientOrderId: "cond-" + c.Id,
+		}
+		orderId, submitErr := e.submitter.SubmitTradeContext(ctx, order)
+		e.mu.Lock()
+		delete(e.submitting, c.Id)
+		switch {
+		case submitErr == nil:
+			c.OrderId = orderId
+			c.Error = ""
+			logrus.Infof("条件单 %s(%s %s) 已触发，最新价 %s，委托编号 %s", c.Id, c.Kind, c.Code, last.String(), orderId)
+			e.notifyLocked(*c)
+			e.cancelSiblingsLocked(c)
+		case errors.Is(submitErr, client.ErrOrderStatusUnknown), errors.Is(submitErr, client.ErrClientOrderInFlight):
+			// 委托可能已经提交：保持已触发、没有委托编号，下次检查时查找或者重新提交
+			c.Error = submitErr.Error()
+			logrus.Warnf("条件单 %s(%s %s) 触发后委托结果未知，稍后重新查找: %s", c.Id, c.Kind, c.Code, submitErr.Error())
+		default:
+			// 同一个 OCO 组的其他条件单继续等待触发，持仓仍然受到保护
+			c.Status = StatusFailed
+			c.Error = submitErr.Error()
+			logrus.Errorf("条件单 %s(%s %s) 触发后提交委托失败: %s", c.Id, c.Kind, c.Code, submitErr.Error())
+			e.notifyLocked(*c)
+		}
+		if saveErr := e.saveLocked(); saveErr != nil {
+			err = saveErr
+		}
+		e.mu.Unlock()
+	}
+	return err
+}
+
+// submittingLocked 同一个 OCO 组中是否有其他条件单已触发、正在提交委托或者委托结果未知
+func (e *Engine) submittingLocked(c *Condition) bool {
+	if c.OcoGroup == "" {
+		return false
+	}
+	for _, other := range e.conditions {
+		if other.OcoGroup == c.OcoGroup && other.Id != c.Id && other.awaitingOrder() {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelSiblingsLocked 委托提交成功后取消同一个 OCO 组中其余等待触发的条件单
+func (e *Engine) cancelSiblingsLocked(c *Condition) {
+	if c.OcoGroup == "" {
+		return
+	}
+	for _, other := range e.conditions {
+		if other.OcoGroup == c.OcoGroup && other.Id != c.Id && other.Status == StatusPending {
+			other.Status = StatusCancelled
+			e.notifyLocked(*other)
+		}
+	}
+}
+
+// sortedLocked 按添加时间排序的条件单，同一个 OCO 组同时满足条件时先添加的优先
+func (e *Engine) sortedLocked() []*Condition {
+	list := make([]*Condition, 0, len(e.conditions))
+	for _, c := range e.conditions {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+func (e *Engine) notifyLocked(c Condition) {
+	for _, fn := range e.callbacks {
+		fn(c)
+	}
+}
+
+// saveLocked 保存等待触发的条件单，以及已触发但委托还没有提交完成的条件单
+func (e *Engine) saveLocked() error {
+	if e.config.Store == nil {
+		return nil
+	}
+	list := make([]*Condition, 0, len(e.conditions))
+	for _, c := range e.sortedLocked() {
+		if c.Status == StatusPending || c.awaitingOrder() {
+			list = append(list, c)
+		}
+	}
+	if err := e.config.Store.Save(list); err != nil {
+		return errors.Wrap(err, "保存条件单失败")
+	}
+	return nil
+}
+
+func newId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,307 @@
+package conditional
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// fakeSubmitter 依次返回 errs 中的错误，用完后提交成功
+type fakeSubmitter struct {
+	mu     sync.Mutex
+	errs   []error
+	orders []model.TradeOrderForm
+}
+
+func (f *fakeSubmitter) SubmitTradeContext(ctx context.Context, order model.TradeOrderForm) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return "", err
+	}
+	f.orders = append(f.orders, order)
+	return "order-" + order.ClientOrderId, nil
+}
+
+func quoteAt(price *float64) QuoteFunc {
+	return func(ctx context.Context, code string) (*model.Stockquote, error) {
+		return &model.Stockquote{Code: code, NewestPrice: *price, BuyPrice1: *price - 0.001, SalePrice1: *price + 0.001}, nil
+	}
+}
+
+func newOCO(t *testing.T, submitter Submitter, price *float64) (*Engine, string, string) {
+	t.Helper()
+	e, err := NewEngine(submitter, Config{Quote: quoteAt(price)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := e.AddOCO(
+		Condition{Kind: StopLoss, Code: "510300", TradeType: model.TradeTypeSale, Amount: 1000, TriggerPrice: decimal.RequireFromString("3.8")},
+		Condition{Kind: TakeProfit, Code: "510300", TradeType: model.TradeTypeSale, Amount: 1000, TriggerPrice: decimal.RequireFromString("4.3")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, ids[0], ids[1]
+}
+
+func TestOCOSubmitFailedKeepsSibling(t *testing.T) {
+	price := 3.9
+	submitter := &fakeSubmitter{errs: []error{errors.New("委托被风控拒绝")}}
+	e, stop, profit := newOCO(t, submitter, &price)
+
+	// 触发止损但提交失败：止盈继续等待触发
+	price = 3.79
+	e.Poll(context.Background())
+	if c, _ := e.Get(stop); c.Status != StatusFailed {
+		t.Fatalf("止损应该为 failed，实际为 %s", c.Status)
+	}
+	if c, _ := e.Get(profit); c.Status != StatusPending {
+		t.Fatalf("止损提交失败后止盈应该继续等待触发，实际为 %s", c.Status)
+	}
+
+	// 止盈触发并提交成功
+	price = 4.31
+	e.Poll(context.Background())
+	c, _ := e.Get(profit)
+	if c.Status != StatusTriggered || c.OrderId == "" {
+		t.Fatalf("止盈应该已触发并提交委托，实际为 %s %q", c.Status, c.OrderId)
+	}
+	if len(submitter.orders) != 1 || submitter.orders[0].ClientOrderId != "cond-"+profit {
+		t.Fatalf("提交的委托错误: %+v", submitter.orders)
+	}
+}
+
+func TestOCOSubmitSucceededCancelsSibling(t *testing.T) {
+	price := 3.9
+	submitter := &fakeSubmitter{}
+	e, stop, profit := newOCO(t, submitter, &price)
+	var events []Condition
+	e.OnEvent(func(c Condition) { events = append(events, c) })
+
+	price = 3.79
+	e.Poll(context.Background())
+	if c, _ := e.Get(stop); c.Status != StatusTriggered || c.OrderId == "" {
+		t.Fatalf("止损应该已触发并提交委托，实际为 %s %q", c.Status, c.OrderId)
+	}
+	if c, _ := e.Get(profit); c.Status != StatusCancelled {
+		t.Fatalf("止损提交成功后止盈应该取消，实际为 %s", c.Status)
+	}
+	// 先通知触发，再通知取消
+	if len(events) != 2 || events[0].Id != stop || events[1].Id != profit || events[1].Status != StatusCancelled {
+		t.Fatalf("事件错误: %+v", events)
+	}
+
+	price = 4.31
+	e.Poll(context.Background())
+	if len(submitter.orders) != 1 {
+		t.Fatalf("止盈已取消，不应该再提交委托: %+v", submitter.orders)
+	}
+}
+
+func TestOCOAmbiguousSubmitRetried(t *testing.T) {
+	price := 3.9
+	submitter := &fakeSubmitter{errs: []error{&client.AmbiguousSubmitError{Err: errors.New("请求超时")}}}
+	e, stop, profit := newOCO(t, submitter, &price)
+	var events []Condition
+	e.OnEvent(func(c Condition) { events = append(events, c) })
+
+	// 止损触发但结果未知：保持已触发，止盈暂停触发
+	price = 3.79
+	e.Poll(context.Background())
+	c, _ := e.Get(stop)
+	if c.Status != StatusTriggered || c.OrderId != "" || c.Error == "" {
+		t.Fatalf("结果未知时止损应该保持已触发，实际为 %s %q %q", c.Status, c.OrderId, c.Error)
+	}
+	if c, _ := e.Get(profit); c.Status != StatusPending {
+		t.Fatalf("止盈应该继续等待，实际为 %s", c.Status)
+	}
+	if len(events) != 0 {
+		t.Fatalf("结果未知时不应该发布事件: %+v", events)
+	}
+
+	// 止盈满足条件也不触发，止损使用相同的客户端委托编号重新提交
+	price = 4.31
+	e.Poll(context.Background())
+	if c, _ := e.Get(stop); c.Status != StatusTriggered || c.OrderId != "order-cond-"+stop || c.Error != "" {
+		t.Fatalf("止损应该拿到委托编号，实际为 %s %q %q", c.Status, c.OrderId, c.Error)
+	}
+	if c, _ := e.Get(profit); c.Status != StatusCancelled {
+		t.Fatalf("止损提交成功后止盈应该取消，实际为 %s", c.Status)
+	}
+	if len(submitter.orders) != 1 || submitter.orders[0].ClientOrderId != "cond-"+stop {
+		t.Fatalf("提交的委托错误: %+v", submitter.orders)
+	}
+}
+
+func TestAmbiguousSubmitReconciled(t *testing.T) {
+	s := fakebroker.New()
+	t.Cleanup(s.Close)
+	s.AddAccount("a", decimal.NewFromInt(1000000))
+	if err := s.AddPosition("a", "510300", "沪深300ETF", 1000, decimal.RequireFromString("3.9")); err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.NewEastMoneyClient(client.EastMoneyClientConfig{
+		Account:              "a",
+		BaseURL:              s.URL,
+		Recognizer:           fakebroker.FixedRecognizer(s.Captcha()),
+		SubmitLookupInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	price := 3.9
+	e, stop, profit := newOCO(t, c, &price)
+	// 委托已经到达券商但响应丢失，且委托列表延迟更新，第一次检查时找不到
+	s.DropSubmits(1, true)
+	s.DelayOrders(3)
+	price = 3.79
+	e.Poll(context.Background())
+	if cond, _ := e.Get(stop); !cond.awaitingOrder() {
+		t.Fatalf("止损应该保持已触发、结果未知，实际为 %s %q", cond.Status, cond.OrderId)
+	}
+
+	// 再次检查时在当日委托中找到，不会重复委托
+	e.Poll(context.Background())
+	cond, _ := e.Get(stop)
+	if cond.Status != StatusTriggered || cond.OrderId == "" {
+		t.Fatalf("止损应该拿到委托编号，实际为 %s %q", cond.Status, cond.OrderId)
+	}
+	if cond, _ := e.Get(profit); cond.Status != StatusCancelled {
+		t.Fatalf("止盈应该取消，实际为 %s", cond.Status)
+	}
+	orders, err := c.GetOrdersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].OrderId != cond.OrderId {
+		t.Fatalf("委托重复或者没有找回: %+v", orders)
+	}
+}
+
+func TestTrailingStopUpdate(t *testing.T) {
+	sell := Condition{Kind: TrailingStop, TradeType: model.TradeTypeSale, TrailPercent: decimal.RequireFromString("0.05")}
+	steps := []struct {
+		last      string
+		triggered bool
+		moved     bool
+		extreme   string
+	}{
+		{"10", false, true, "10"},
+		{"9.8", false, false, "10"},
+		{"11", false, true, "11"},
+		{"10.46", false, false, "11"},
+		// 11 * 0.95
+		{"10.45", true, false, "11"},
+	}
+	for i, step := range steps {
+		triggered, moved := sell.update(decimal.RequireFromString(step.last))
+		if triggered != step.triggered || moved != step.moved || !sell.Extreme.Equal(decimal.RequireFromString(step.extreme)) {
+			t.Fatalf("卖出第 %d 步: triggered=%v moved=%v extreme=%s", i, triggered, moved, sell.Extreme)
+		}
+	}
+
+	buy := Condition{Kind: TrailingStop, TradeType: model.TradeTypeBuy, TrailAmount: decimal.RequireFromString("0.2"), Extreme: decimal.RequireFromString("5")}
+	steps = []struct {
+		last      string
+		triggered bool
+		moved     bool
+		extreme   string
+	}{
+		{"5.1", false, false, "5"},
+		{"4.8", false, true, "4.8"},
+		{"4.99", false, false, "4.8"},
+		{"5", true, false, "4.8"},
+	}
+	for i, step := range steps {
+		triggered, moved := buy.update(decimal.RequireFromString(step.last))
+		if triggered != step.triggered || moved != step.moved || !buy.Extreme.Equal(decimal.RequireFromString(step.extreme)) {
+			t.Fatalf("买入第 %d 步: triggered=%v moved=%v extreme=%s", i, triggered, moved, buy.Extreme)
+		}
+	}
+}
+
+func TestStoreRestore(t *testing.T) {
+	store := &FileStore{Path: filepath.Join(t.TempDir(), "conditions.json")}
+	if conditions, err := store.Load(); err != nil || conditions != nil {
+		t.Fatalf("没有保存过时应该返回 nil, nil，实际为 %v %v", conditions, err)
+	}
+	price := 10.0
+	e, err := NewEngine(&fakeSubmitter{}, Config{Store: store, Quote: quoteAt(&price)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trailing, err := e.Add(Condition{Kind: TrailingStop, Code: "600000", TradeType: model.TradeTypeSale, Amount: 100, TrailPercent: decimal.RequireFromString("0.05")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop, err := e.Add(Condition{Kind: StopLoss, Code: "600000", TradeType: model.TradeTypeSale, Amount: 100, TriggerPrice: decimal.RequireFromString("9")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := e.Add(Condition{Kind: TakeProfit, Code: "600000", TradeType: model.TradeTypeSale, Amount: 100, TriggerPrice: decimal.RequireFromString("12")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Cancel(cancelled); err != nil {
+		t.Fatal(err)
+	}
+	e.Poll(context.Background())
+	// 最高价变化后保存
+	price = 10.5
+	e.Poll(context.Background())
+
+	restored, err := NewEngine(&fakeSubmitter{}, Config{Store: store, Quote: quoteAt(&price)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.Conditions()) != 2 {
+		t.Fatalf("应该只恢复等待触发的条件单: %+v", restored.Conditions())
+	}
+	c, ok := restored.Get(trailing)
+	if !ok || c.Status != StatusPending || !c.Extreme.Equal(decimal.RequireFromString("10.5")) {
+		t.Fatalf("跟踪止损恢复错误: %+v", c)
+	}
+	if c, ok := restored.Get(stop); !ok || c.Status != StatusPending || !c.TriggerPrice.Equal(decimal.RequireFromString("9")) {
+		t.Fatalf("止损恢复错误: %+v", c)
+	}
+}
+
+func TestRestoreTriggeredWithoutOrderId(t *testing.T) {
+	store := &FileStore{Path: filepath.Join(t.TempDir(), "conditions.json")}
+	err := store.Save([]*Condition{
+		{Id: "unknown", Kind: StopLoss, Code: "600000", TradeType: model.TradeTypeSale, Amount: 100, TriggerPrice: decimal.RequireFromString("9"), Status: StatusTriggered},
+		{Id: "pending", Kind: StopLoss, Code: "600000", TradeType: model.TradeTypeSale, Amount: 100, TriggerPrice: decimal.RequireFromString("9"), Status: StatusPending},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	price := 8.0
+	submitter := &fakeSubmitter{}
+	e, err := NewEngine(submitter, Config{Store: store, Quote: quoteAt(&price)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := e.Get("unknown")
+	if c.Status != StatusFailed || c.Error == "" {
+		t.Fatalf("退出前已触发、没有委托编号的条件单应该标记为失败，实际为 %s %q", c.Status, c.Error)
+	}
+	// 不会自动重新提交，等待触发的条件单正常触发
+	e.Poll(context.Background())
+	if len(submitter.orders) != 1 || submitter.orders[0].ClientOrderId != "cond-pending" {
+		t.Fatalf("提交的委托错误: %+v", submitter.orders)
+	}
+}
@@ -0,0 +1,55 @@
+package conditional
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Store 条件单的存储，保存的是所有等待触发的条件单。没有保存过时 Load 返回 nil, nil
+type Store interface {
+	Load() ([]*Condition, error)
+	Save(conditions []*Condition) error
+}
+
+// FileStore 以 json 保存条件单
+type FileStore struct {
+	Path string
+}
+
+func (f *FileStore) Load() ([]*Condition, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var conditions []*Condition
+	if err := json.Unmarshal(data, &conditions); err != nil {
+		return nil, err
+	}
+	return conditions, nil
+}
+
+func (f *FileStore) Save(conditions []*Condition) error {
+	data, err := json.MarshalIndent(conditions, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先写入临时文件再重命名，避免写入一半时进程退出导致文件损坏
+	tmp, err := ioutil.TempFile(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
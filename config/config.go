@@ -1,8 +1,10 @@
 package config
 
 import (
+	"context"
 	"sync"
 
+	"github.com/yfjiang-danny/eastmoneyapi/api"
 	"github.com/yfjiang-danny/eastmoneyapi/client"
 
 	"github.com/spf13/viper"
@@ -12,6 +14,10 @@ var defaultConfigFile = "./configs/config.yaml"
 
 type Config struct {
 	EastMoneyClientConfig client.EastMoneyClientConfig
+	// 多账户配置，key 为账户名称
+	Accounts map[string]client.EastMoneyClientConfig
+	// 行情接口配置，GetConfig 读取配置后会设置为 api 包的默认客户端
+	ApiConfig api.Config
 }
 
 var conf *Config
@@ -26,11 +32,19 @@ func GetConfig() *Config {
 		if err := viper.Unmarshal(&conf); err != nil {
 			panic(err)
 		}
+		api.SetDefault(api.NewClient(conf.ApiConfig))
 	})
 	return conf
 
 }
 
+// NewAccountManager 根据配置中的 Accounts 创建多账户管理器，所有账户同时登录，
+// 有账户登录失败时返回错误，登录成功的账户仍然保留在返回的管理器中
+func NewAccountManager(ctx context.Context) (*client.AccountManager, error) {
+	m := client.NewAccountManager()
+	return m, m.AddAll(ctx, GetConfig().Accounts)
+}
+
 func SetConfigPath(path string) {
 	defaultConfigFile = path
 }
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/yfjiang-danny/eastmoneyapi/api"
+)
+
+func TestGetConfigSetsApiDefault(t *testing.T) {
+	var requested bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.Write([]byte(`{"data":{"f57":"510300","f43":3856}}`))
+	}))
+	defer ts.Close()
+	old := api.Default()
+	defer api.SetDefault(old)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "eastmoneyclientconfig:\n  account: \"540000000001\"\n" +
+		"apiconfig:\n  quotebaseurl: \"" + ts.URL + "\"\n  klinebaseurl: \"" + ts.URL + "\"\n  timeout: 2s\n"
+	if err := ioutil.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	SetConfigPath(path)
+	c := GetConfig()
+	if c.EastMoneyClientConfig.Account != "540000000001" || c.ApiConfig.QuoteBaseURL != ts.URL {
+		t.Fatalf("配置读取错误: %+v", c)
+	}
+	if _, err := api.GetQuote("510300"); err != nil {
+		t.Fatal(err)
+	}
+	if !requested {
+		t.Fatal("GetConfig 之后行情请求应该发往 ApiConfig 中的地址")
+	}
+}
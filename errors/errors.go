@@ -0,0 +1,121 @@
+// Package errors 东财接口返回的业务错误
+// 东财的接口通过 Status/Errcode/Message 返回错误，这里先根据 Status/Errcode、再根据消息关键字将错误归类，调用方可以通过 errors.Is 判断错误类型：
+//
+//	if errors.Is(err, em_errors.ErrInsufficientFunds) {
+//		...
+//	}
+package errors
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// 错误类型
+var (
+	ErrInsufficientFunds    = errors.New("可用资金不足")
+	ErrInsufficientPosition = errors.New("可用持仓不足")
+	ErrOutsideTradingHours  = errors.New("非交易时间")
+	ErrPriceOutOfLimit      = errors.New("委托价格超出涨跌停限制")
+	ErrInvalidCode          = errors.New("证券代码错误")
+	ErrSessionExpired       = errors.New("会话已失效")
+	ErrRateLimited          = errors.New("请求过于频繁")
+)
+
+// 未登录或者会话超时时返回的 Status 和 Errcode
+const CodeSessionExpired = -2
+
+// 错误码对应的错误类型，优先于消息关键字，可以通过 RegisterErrcode 补充
+var (
+	errcodesMu sync.RWMutex
+	errcodes   = map[int]error{
+		CodeSessionExpired: ErrSessionExpired,
+	}
+)
+
+// RegisterErrcode 登记 Errcode 对应的错误类型，用于补充实际遇到的错误码
+func RegisterErrcode(errcode int, kind error) {
+	errcodesMu.Lock()
+	defer errcodesMu.Unlock()
+	errcodes[errcode] = kind
+}
+
+// 每种错误类型对应的消息关键字，按顺序匹配。
+// 关键字需要足够具体，例如 "请稍后" 会匹配到 "系统繁忙，请稍后再试"，"交易时间" 会匹配到任何提到交易时间的消息
+var rules = []struct {
+	kind     error
+	keywords []string
+}{
+	{ErrSessionExpired, []string{"重新登录", "会话已超时", "会话超时", "会话已失效", "会话失效", "登录超时", "未登录", "请先登录"}},
+	{ErrRateLimited, []string{"过于频繁", "请求频繁", "操作频繁", "访问过快", "请求过快"}},
+	{ErrInsufficientFunds, []string{"资金不足", "余额不足"}},
+	{ErrInsufficientPosition, []string{"股份不足", "持仓不足", "可卖数量不足", "可用数量不足"}},
+	{ErrOutsideTradingHours, []string{"非交易时间", "不在交易时间", "交易时间外", "已闭市", "已休市", "已收市"}},
+	{ErrPriceOutOfLimit, []string{"涨跌幅限制", "超出涨跌停", "高于涨停价", "低于跌停价", "价格超出", "超出价格", "价格无效", "价格范围"}},
+	{ErrInvalidCode, []string{"证券代码错误", "证券代码不存在", "代码错误", "代码不存在", "无此证券", "证券不存在"}},
+}
+
+// BrokerError 东财接口返回的错误，Kind 为上面定义的错误类型之一，无法识别时为 nil
+type BrokerError struct {
+	Status  int
+	Errcode int
+	Message string
+	Kind    error
+}
+
+func (e *BrokerError) Error() string {
+	return fmt.Sprintf("东财接口返回错误(Status=%d, Errcode=%d): %s", e.Status, e.Errcode, e.Message)
+}
+
+func (e *BrokerError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+func (e *BrokerError) Unwrap() error {
+	return e.Kind
+}
+
+// Parse 根据接口返回的 Status/Errcode/Message 生成错误，Status 为0时返回 nil
+func Parse(status, errcode int, message string) error {
+	if status == 0 {
+		return nil
+	}
+	kind := ClassifyCode(status, errcode)
+	if kind == nil {
+		kind = Classify(message)
+	}
+	return &BrokerError{
+		Status:  status,
+		Errcode: errcode,
+		Message: message,
+		Kind:    kind,
+	}
+}
+
+// ClassifyCode 根据 Status/Errcode 判断错误类型，无法识别时返回 nil
+func ClassifyCode(status, errcode int) error {
+	if status == 0 {
+		return nil
+	}
+	if status == CodeSessionExpired {
+		return ErrSessionExpired
+	}
+	errcodesMu.RLock()
+	defer errcodesMu.RUnlock()
+	return errcodes[errcode]
+}
+
+// Classify 根据消息判断错误类型，无法识别时返回 nil
+func Classify(message string) error {
+	for _, rule := range rules {
+		for _, keyword := range rule.keywords {
+			if strings.Contains(message, keyword) {
+				return rule.kind
+			}
+		}
+	}
+	return nil
+}
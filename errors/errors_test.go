@@ -0,0 +1,73 @@
+package errors
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		message string
+		want    error
+	}{
+		{"会话已超时，请重新登录!", ErrSessionExpired},
+		{"您还未登录或者登录超时", ErrSessionExpired},
+		{"请求过于频繁，请稍后再试", ErrRateLimited},
+		{"可用资金不足", ErrInsufficientFunds},
+		{"[510300]可用股份不足", ErrInsufficientPosition},
+		{"当前为非交易时间，不能委托", ErrOutsideTradingHours},
+		{"委托价格超出涨跌幅限制", ErrPriceOutOfLimit},
+		{"证券代码不存在", ErrInvalidCode},
+		// 过于宽泛的消息不能被误判
+		{"系统繁忙，请稍后再试", nil},
+		{"交易时间为9:30-11:30，13:00-15:00", nil},
+		{"会话编号: 12345", nil},
+		{"", nil},
+	}
+	for _, c := range cases {
+		if got := Classify(c.message); got != c.want {
+			t.Errorf("Classify(%q) = %v，应该为 %v", c.message, got, c.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	if err := Parse(0, 0, "可用资金不足"); err != nil {
+		t.Fatalf("Status 为0时应该返回 nil，实际为 %v", err)
+	}
+
+	// 会话失效根据 Status/Errcode 判断，与消息无关
+	err := Parse(CodeSessionExpired, 0, "")
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("Status=-2 应该为 ErrSessionExpired，实际为 %v", err)
+	}
+	if err := Parse(-1, CodeSessionExpired, "unknown"); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("Errcode=-2 应该为 ErrSessionExpired，实际为 %v", err)
+	}
+
+	// 错误码优先于消息关键字
+	RegisterErrcode(-9001, ErrRateLimited)
+	defer func() {
+		errcodesMu.Lock()
+		delete(errcodes, -9001)
+		errcodesMu.Unlock()
+	}()
+	err = Parse(-1, -9001, "可用资金不足")
+	if !errors.Is(err, ErrRateLimited) || errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("应该按照错误码归类为 ErrRateLimited，实际为 %v", err)
+	}
+
+	err = Parse(-1, -1, "可用资金不足")
+	var brokerErr *BrokerError
+	if !errors.As(err, &brokerErr) || brokerErr.Status != -1 || brokerErr.Message != "可用资金不足" {
+		t.Fatalf("应该返回 *BrokerError，实际为 %#v", err)
+	}
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("应该按照消息归类为 ErrInsufficientFunds，实际为 %v", err)
+	}
+
+	if err := Parse(-1, -1, "系统繁忙，请稍后再试"); errors.Is(err, ErrRateLimited) {
+		t.Fatal("系统繁忙不应该归类为 ErrRateLimited")
+	}
+}
@@ -0,0 +1,577 @@
+// Package execution 算法交易：将大额委托在一段时间内拆分为多个子委托执行，避免一次性委托冲击价格。
+// TWAP 在交易时间上平均拆分，VWAP 按照分钟K线计算的日内成交量分布拆分。
+// 子委托的价格取自实时盘口，超时未成交的子委托撤单后以对手价重新委托
+package execution
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/api"
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// Algorithm 拆单算法
+type Algorithm string
+
+const (
+	TWAP Algorithm = "twap"
+	VWAP Algorithm = "vwap"
+)
+
+const (
+	defaultLotSize      = 100
+	defaultSlices       = 10
+	defaultRepriceAfter = 30 * time.Second
+	defaultPollInterval = 2 * time.Second
+)
+
+// ErrNotCompleted 到达截止时间后委托没有全部成交，剩余的子委托已撤单
+var ErrNotCompleted = errors.New("算法委托没有全部成交")
+
+// ErrOddLotRemainder 买入的子委托撤单前部分成交了不足一手的数量，剩余的数量（见 Progress.Unsendable）不足一手，无法继续买入
+var ErrOddLotRemainder = errors.New("算法委托剩余数量不足一手，无法继续买入")
+
+// Trader 提交、查询和撤销委托，*client.EastMoneyClient 实现了该接口。
+// FindClientOrderContext 只在当日委托中查找提交结果未知的委托，不会提交委托
+type Trader interface {
+	SubmitTradeContext(ctx context.Context, order model.TradeOrderForm) (string, error)
+	FindClientOrderContext(ctx context.Context, clientOrderId string) (string, error)
+	GetOrdersListContext(ctx context.Context) ([]*model.Order, error)
+	RevokeOrdersDetailedContext(ctx context.Context, list []*model.Order) ([]model.RevokeResult, error)
+}
+
+// QuoteFunc 查询证券的最新行情
+type QuoteFunc func(ctx context.Context, code string) (*model.Stockquote, error)
+
+// ParentOrder 母单
+type ParentOrder struct {
+	Code      string
+	Name      string
+	TradeType model.TradeType
+	Amount    int
+	// 执行的时间段，只在其中的交易时间拆分；使用自定义的 Schedule 时 End 默认为最后一个 Slice 的时间
+	Start time.Time
+	End   time.Time
+
+	Algorithm Algorithm
+	// 子委托的个数，默认10个
+	Slices int
+	// VWAP 使用的日内成交量分布，为空时查询最近几个交易日的5分钟K线计算
+	Profile Profile
+	// 自定义的拆分计划，设置后忽略 Algorithm 和 Slices
+	Schedule []Slice
+
+	// 限价：买入的最高价、卖出的最低价，为0时不限制
+	LimitPrice decimal.Decimal
+	// 每手的数量，默认100
+	LotSize int
+	// 子委托超过这个时间没有全部成交时撤单，剩余数量以对手价重新委托，默认30秒
+	RepriceAfter time.Duration
+	// 到达 End 之后继续以对手价委托的时间，超过后撤销所有子委托并返回 ErrNotCompleted，默认为 2 * RepriceAfter
+	GracePeriod time.Duration
+	// 查询委托状态的间隔，默认2秒
+	PollInterval time.Duration
+}
+
+// ChildOrder 子委托
+type ChildOrder struct {
+	// 提交结果未知时 OrderId 为空，之后使用相同的客户端委托编号查找或者重新提交
+	OrderId       string
+	ClientOrderId string
+	// 撤销所有子委托时仍然无法确定是否已经提交，需要检查当日委托
+	Unresolved   bool
+	Price        decimal.Decimal
+	Amount       int
+	FilledAmount int
+	FilledValue  decimal.Decimal
+	Status       model.OrderStatus
+	// 是否以对手价委托
+	Aggressive  bool
+	SubmittedAt time.Time
+	// 已经提交了撤单，等待撤单完成
+	Revoking bool
+
+	date string // 委托日期，撤单时使用
+}
+
+func (c *ChildOrder) working() bool {
+	return !c.Unresolved && !c.Status.IsFinal()
+}
+
+// Progress 执行进度
+type Progress struct {
+	Code      string
+	TradeType model.TradeType
+	Amount    int
+	// 累计成交数量、成交均价
+	FilledAmount int
+	AvgFillPrice decimal.Decimal
+	// 已委托未成交的数量
+	WorkingAmount int
+	// 到达价格：开始执行时的买1卖1中间价（没有盘口时为最新价）
+	ArrivalPrice decimal.Decimal
+	// 相对到达价格的滑点，单位为基点（万分之一），正数表示成本（买入成交价高于到达价格或者卖出成交价低于到达价格）
+	Slippage decimal.Decimal
+	// 买入时剩余的不足一手、无法再委托的数量
+	Unsendable int
+	Children   []ChildOrder
+	Done       bool
+}
+
+// Executor 算法委托的执行器
+type Executor struct {
+	trader     Trader
+	quote      QuoteFunc
+	kline      KlineFunc
+	onProgress []func(Progress)
+}
+
+// NewExecutor 创建执行器，quote 为空时使用 api.GetQuoteContext，kline（VWAP 计算成交量分布）为空时使用 api.GetKlineContext
+func NewExecutor(trader Trader, quote QuoteFunc, kline KlineFunc) *Executor {
+	if quote == nil {
+		quote = api.GetQuoteContext
+	}
+	if kline == nil {
+		kline = api.GetKlineContext
+	}
+	return &Executor{trader: trader, quote: quote, kline: kline}
+}
+
+// OnProgress 注册回调，每次查询委托状态后调用
+func (x *Executor) OnProgress(fn func(Progress)) {
+	x.onProgress = append(x.onProgress, fn)
+}
+
+// Execute 执行母单，阻塞到全部成交、到达截止时间或者 ctx 被取消。
+// ctx 被取消或者到达截止时间时会撤销所有未成交的子委托；
+// 买入时部分成交后剩余的数量不足一手时提前结束，返回 ErrOddLotRemainder
+func (x *Executor) Execute(ctx context.Context, parent ParentOrder) (*Progress, error) {
+	schedule, err := x.prepare(ctx, &parent)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := x.quote(ctx, parent.Code)
+	if err != nil {
+		return nil, errors.Wrap(err, "查询到达价格失败")
+	}
+	run := &execution{
+		x:        x,
+		parent:   parent,
+		schedule: schedule,
+		id:       newId(),
+		arrival:  arrivalPrice(quote),
+	}
+	return run.loop(ctx)
+}
+
+// prepare 检查母单并设置默认值，返回拆分计划
+func (x *Executor) prepare(ctx context.Context, p *ParentOrder) ([]Slice, error) {
+	if p.Code == "" || p.Amount <= 0 {
+		return nil, errors.New("母单的证券代码或者数量错误")
+	}
+	if p.TradeType != model.TradeTypeBuy && p.TradeType != model.TradeTypeSale {
+		return nil, errors.New("委托方向错误")
+	}
+	if p.LotSize <= 0 {
+		p.LotSize = defaultLotSize
+	}
+	if p.TradeType == model.TradeTypeBuy && p.Amount%p.LotSize != 0 {
+		return nil, errors.Errorf("买入数量必须为 %d 的整数倍", p.LotSize)
+	}
+	if p.Slices <= 0 {
+		p.Slices = defaultSlices
+	}
+	if p.RepriceAfter <= 0 {
+		p.RepriceAfter = defaultRepriceAfter
+	}
+	if p.GracePeriod <= 0 {
+		p.GracePeriod = 2 * p.RepriceAfter
+	}
+	if p.PollInterval <= 0 {
+		p.PollInterval = defaultPollInterval
+	}
+	if len(p.Schedule) > 0 {
+		if last := p.Schedule[len(p.Schedule)-1].At; p.End.Before(last) {
+			p.End = last
+		}
+		return p.Schedule, nil
+	}
+	if !p.End.After(p.Start) {
+		return nil, errors.New("结束时间必须晚于开始时间")
+	}
+	switch p.Algorithm {
+	case TWAP:
+		return ScheduleTWAP(p.Amount, p.Slices, p.LotSize, p.Start, p.End)
+	case VWAP:
+		if len(p.Profile) == 0 {
+			profile, err := LoadVolumeProfile(ctx, x.kline, p.Code, model.FiveMinuteKlineType)
+			if err != nil {
+				return nil, errors.Wrap(err, "计算成交量分布失败")
+			}
+			p.Profile = profile
+		}
+		return ScheduleVWAP(p.Amount, p.Slices, p.LotSize, p.Start, p.End, p.Profile)
+	}
+	return nil, errors.Errorf("未知的拆单算法: %s", p.Algorithm)
+}
+
+// execution 一次母单的执行状态
+type execution struct {
+	x        *Executor
+	parent   ParentOrder
+	schedule []Slice
+	id       string
+	arrival  decimal.Decimal
+	children []*ChildOrder
+	// 有子委托因为超时撤单，下一个子委托以对手价委托
+	aggressive bool
+}
+
+func (r *execution) loop(ctx context.Context) (*Progress, error) {
+	p := r.parent
+	deadline := p.End.Add(p.GracePeriod)
+	ticker := time.NewTicker(p.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.step(ctx, time.Now(), deadline); err != nil {
+			return r.abort(err)
+		}
+		progress := r.progress()
+		r.publish(progress)
+		if progress.Done {
+			return &progress, nil
+		}
+		if progress.WorkingAmount == 0 && progress.Unsendable > 0 && progress.FilledAmount+progress.Unsendable >= p.Amount {
+			logrus.Warnf("算法委托 %s 剩余 %d 不足一手，无法继续买入", r.id, progress.Unsendable)
+			return &progress, ErrOddLotRemainder
+		}
+		if time.Now().After(deadline) {
+			return r.abort(ErrNotCompleted)
+		}
+		select {
+		case <-ctx.Done():
+			return r.abort(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// step 刷新子委托状态，撤销超时的子委托，并按计划提交新的子委托
+func (r *execution) step(ctx context.Context, now, deadline time.Time) error {
+	if err := r.reconcile(ctx); err != nil {
+		return err
+	}
+	if err := r.refresh(ctx); err != nil {
+		return err
+	}
+	p := r.parent
+
+	var stale []*ChildOrder
+	for _, c := range r.children {
+		if c.working() && !c.Revoking && now.Sub(c.SubmittedAt) >= p.RepriceAfter {
+			stale = append(stale, c)
+		}
+	}
+	if len(stale) > 0 {
+		if err := r.revoke(ctx, stale); err != nil {
+			return err
+		}
+		r.aggressive = true
+	}
+
+	filled, working := r.amounts()
+	if filled >= p.Amount {
+		return nil
+	}
+	target := 0
+	for _, s := range r.schedule {
+		if !s.At.After(now) {
+			target += s.Amount
+		}
+	}
+	final := target >= p.Amount
+	toSend := target - filled - working
+	if !final || p.TradeType == model.TradeTypeBuy {
+		toSend = toSend / p.LotSize * p.LotSize
+	}
+	if toSend <= 0 || now.After(deadline) {
+		return nil
+	}
+
+	quote, err := r.x.quote(ctx, p.Code)
+	if err != nil {
+		logrus.Warnf("算法委托 %s 查询行情失败: %s", r.id, err.Error())
+		return nil
+	}
+	aggressive := r.aggressive || now.After(p.End)
+	price := childPrice(quote, p.TradeType, aggressive)
+	if !price.IsPositive() {
+		return nil
+	}
+	if p.LimitPrice.IsPositive() {
+		if p.TradeType == model.TradeTypeBuy && price.GreaterThan(p.LimitPrice) {
+			price = p.LimitPrice
+		}
+		if p.TradeType == model.TradeTypeSale && price.LessThan(p.LimitPrice) {
+			price = p.LimitPrice
+		}
+	}
+	child := &ChildOrder{
+		ClientOrderId: r.id + "-" + strconv.Itoa(len(r.children)+1),
+		Price:         price,
+		Amount:        toSend,
+		Status:        model.OrderStatusUnreported,
+		Aggressive:    aggressive,
+		SubmittedAt:   now,
+	}
+	orderId, err := r.x.trader.SubmitTradeContext(ctx, child.form(p))
+	if err != nil && !unknownSubmit(err) {
+		return errors.Wrap(err, "提交子委托失败")
+	}
+	if err != nil {
+		// 委托可能已经提交：计入已委托的数量，避免超出母单的数量，之后查找或者重新提交
+		logrus.Warnf("算法委托 %s 子委托 %s 提交结果未知: %s", r.id, child.ClientOrderId, err.Error())
+	}
+	child.OrderId = orderId
+	r.children = append(r.children, child)
+	r.aggressive = false
+	return nil
+}
+
+// form 子委托的委托
+func (c *ChildOrder) form(p ParentOrder) model.TradeOrderForm {
+	return model.TradeOrderForm{
+		Code:          p.Code,
+		Name:          p.Name,
+		Price:         c.Price,
+		Amount:        c.Amount,
+		TradeType:     p.TradeType,
+		ClientOrderId: c.ClientOrderId,
+	}
+}
+
+// reconcile 使用相同的客户端委托编号重新提交结果未知的子委托，客户端会先在当日委托中查找，不会重复委托。
+// 仍然无法确定时等待下一次，确定提交失败时该子委托结束
+func (r *execution) reconcile(ctx context.Context) error {
+	for _, c := range r.children {
+		if c.OrderId != "" || !c.working() {
+			continue
+		}
+		orderId, err := r.x.trader.SubmitTradeContext(ctx, c.form(r.parent))
+		switch {
+		case err == nil:
+			c.OrderId = orderId
+		case unknownSubmit(err):
+			logrus.Warnf("算法委托 %s 子委托 %s 提交结果仍然未知: %s", r.id, c.ClientOrderId, err.Error())
+		default:
+			return errors.Wrap(err, "重新提交子委托失败")
+		}
+	}
+	return nil
+}
+
+// resolve 只在当日委托中查找结果未知的子委托，不会提交委托。撤销所有子委托时使用，
+// 找到后按普通的子委托撤单，没有找到时标记为 Unresolved，不再等待
+func (r *execution) resolve(ctx context.Context) {
+	for _, c := range r.children {
+		if c.OrderId != "" || !c.working() {
+			continue
+		}
+		orderId, err := r.x.trader.FindClientOrderContext(ctx, c.ClientOrderId)
+		if err != nil || orderId == "" {
+			c.Unresolved = true
+			logrus.Warnf("算法委托 %s 子委托 %s 提交结果未知，请检查当日委托: %v", r.id, c.ClientOrderId, err)
+			continue
+		}
+		c.OrderId = orderId
+	}
+}
+
+// refresh 从当日委托中更新子委托的成交数量和状态
+func (r *execution) refresh(ctx context.Context) error {
+	if len(r.children) == 0 {
+		return nil
+	}
+	orders, err := r.x.trader.GetOrdersListContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "查询子委托状态失败")
+	}
+	byId := make(map[string]*model.Order, len(orders))
+	for _, o := range orders {
+		byId[o.OrderId] = o
+	}
+	for _, c := range r.children {
+		o, ok := byId[c.OrderId]
+		if !ok {
+			continue
+		}
+		c.date = o.Date
+		c.Status = o.OrderStatus()
+		c.FilledAmount = o.ClosingAmount()
+		c.FilledValue = o.ClosingPrice().Mul(decimal.NewFromInt(int64(c.FilledAmount)))
+	}
+	return nil
+}
+
+// revoke 撤销子委托，撤单是否完成在下一次 refresh 时确认
+func (r *execution) revoke(ctx context.Context, children []*ChildOrder) error {
+	list := make([]*model.Order, 0, len(children))
+	for _, c := range children {
+		if c.date == "" {
+			// 还没有出现在当日委托中，下一次再撤
+			continue
+		}
+		list = append(list, &model.Order{OrderId: c.OrderId, Date: c.date})
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	results, err := r.x.trader.RevokeOrdersDetailedContext(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, "撤销子委托失败")
+	}
+	for _, result := range results {
+		for _, c := range children {
+			if c.OrderId == result.OrderId && result.Success {
+				c.Revoking = true
+			}
+		}
+	}
+	return nil
+}
+
+// abort 撤销所有未成交的子委托，等待撤单完成后返回最终的进度
+func (r *execution) abort(cause error) (*Progress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*r.parent.PollInterval)
+	defer cancel()
+	r.resolve(ctx)
+	for ctx.Err() == nil {
+		if err := r.refresh(ctx); err != nil {
+			break
+		}
+		var working []*ChildOrder
+		for _, c := range r.children {
+			if c.working() {
+				working = append(working, c)
+			}
+		}
+		if len(working) == 0 {
+			break
+		}
+		var pending []*ChildOrder
+		for _, c := range working {
+			if !c.Revoking {
+				pending = append(pending, c)
+			}
+		}
+		if err := r.revoke(ctx, pending); err != nil {
+			logrus.Warnf("算法委托 %s 撤销子委托失败: %s", r.id, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.parent.PollInterval):
+		}
+	}
+	progress := r.progress()
+	r.publish(progress)
+	return &progress, cause
+}
+
+// amounts 累计成交数量和已委托未成交的数量
+func (r *execution) amounts() (filled, working int) {
+	for _, c := range r.children {
+		filled += c.FilledAmount
+		if c.working() {
+			working += c.Amount - c.FilledAmount
+		}
+	}
+	return filled, working
+}
+
+func (r *execution) progress() Progress {
+	filled, working := r.amounts()
+	value := decimal.Zero
+	children := make([]ChildOrder, 0, len(r.children))
+	for _, c := range r.children {
+		value = value.Add(c.FilledValue)
+		children = append(children, *c)
+	}
+	progress := Progress{
+		Code:          r.parent.Code,
+		TradeType:     r.parent.TradeType,
+		Amount:        r.parent.Amount,
+		FilledAmount:  filled,
+		WorkingAmount: working,
+		ArrivalPrice:  r.arrival,
+		Children:      children,
+		Done:          filled >= r.parent.Amount,
+	}
+	if r.parent.TradeType == model.TradeTypeBuy && filled < r.parent.Amount {
+		progress.Unsendable = (r.parent.Amount - filled) % r.parent.LotSize
+	}
+	if filled > 0 {
+		progress.AvgFillPrice = value.Div(decimal.NewFromInt(int64(filled)))
+		if r.arrival.IsPositive() {
+			diff := progress.AvgFillPrice.Sub(r.arrival)
+			if r.parent.TradeType == model.TradeTypeSale {
+				diff = diff.Neg()
+			}
+			progress.Slippage = diff.Div(r.arrival).Mul(decimal.NewFromInt(10000)).Round(2)
+		}
+	}
+	return progress
+}
+
+func (r *execution) publish(p Progress) {
+	for _, fn := range r.x.onProgress {
+		fn(p)
+	}
+}
+
+// unknownSubmit 提交结果未知，委托可能已经到达券商
+func unknownSubmit(err error) bool {
+	return errors.Is(err, client.ErrOrderStatusUnknown) || errors.Is(err, client.ErrClientOrderInFlight)
+}
+
+// arrivalPrice 买1卖1的中间价，没有盘口时为最新价
+func arrivalPrice(q *model.Stockquote) decimal.Decimal {
+	bid, ask := decimalFromFloat(q.BuyPrice1), decimalFromFloat(q.SalePrice1)
+	if bid.IsPositive() && ask.IsPositive() {
+		return bid.Add(ask).Div(decimal.NewFromInt(2))
+	}
+	return decimalFromFloat(q.NewestPrice)
+}
+
+// childPrice 子委托的价格：默认挂在己方的最优价（买入为买1价，卖出为卖1价），aggressive 时为对手价。
+// 盘口为空时使用最新价
+func childPrice(q *model.Stockquote, tradeType model.TradeType, aggressive bool) decimal.Decimal {
+	bid, ask := decimalFromFloat(q.BuyPrice1), decimalFromFloat(q.SalePrice1)
+	price := bid
+	if (tradeType == model.TradeTypeBuy) == aggressive {
+		price = ask
+	}
+	if !price.IsPositive() {
+		price = decimalFromFloat(q.NewestPrice)
+	}
+	return price
+}
+
+// decimalFromFloat 行情的价格是 float64，转换时去掉浮点误差
+func decimalFromFloat(f float64) decimal.Decimal {
+	return decimal.NewFromFloat(f).Round(3)
+}
+
+func newId() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "algo-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,203 @@
+package execution
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/fakebroker"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func newTestClient(t *testing.T) (*fakebroker.Server, *client.EastMoneyClient) {
+	t.Helper()
+	s := fakebroker.New()
+	t.Cleanup(s.Close)
+	s.AddAccount("a", decimal.NewFromInt(1000000))
+	c, err := client.NewEastMoneyClient(client.EastMoneyClientConfig{
+		Account:    "a",
+		BaseURL:    s.URL,
+		Recognizer: fakebroker.FixedRecognizer(s.Captcha()),
+
+		SubmitLookupInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return s, c
+}
+
+func fixedQuote(ctx context.Context, code string) (*model.Stockquote, error) {
+	return &model.Stockquote{Code: code, NewestPrice: 3.856, BuyPrice1: 3.855, SalePrice1: 3.857}, nil
+}
+
+func TestOddLotRemainder(t *testing.T) {
+	s, c := newTestClient(t)
+	x := NewExecutor(c, fixedQuote, nil)
+
+	// 第一个子委托成交37股后超时撤单，第二个子委托全部成交，剩余63股不足一手
+	var mu sync.Mutex
+	filled := make(map[string]bool)
+	x.OnProgress(func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		for i, child := range p.Children {
+			if filled[child.OrderId] || child.Status != model.OrderStatusReported {
+				continue
+			}
+			filled[child.OrderId] = true
+			amount := 37
+			if i > 0 {
+				amount = child.Amount
+			}
+			if err := s.Fill(child.OrderId, amount, decimal.RequireFromString("3.856")); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	start := time.Now()
+	p, err := x.Execute(context.Background(), ParentOrder{
+		Code:         "510300",
+		TradeType:    model.TradeTypeBuy,
+		Amount:       200,
+		Schedule:     []Slice{{At: start, Amount: 200}},
+		RepriceAfter: 200 * time.Millisecond,
+		GracePeriod:  time.Minute,
+		PollInterval: 20 * time.Millisecond,
+	})
+	if !errors.Is(err, ErrOddLotRemainder) {
+		t.Fatalf("应该返回 ErrOddLotRemainder，实际为 %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("没有提前结束")
+	}
+	if p.FilledAmount != 137 || p.Unsendable != 63 || p.WorkingAmount != 0 || p.Done {
+		t.Fatalf("进度错误: filled=%d unsendable=%d working=%d done=%v", p.FilledAmount, p.Unsendable, p.WorkingAmount, p.Done)
+	}
+	if len(p.Children) != 2 || p.Children[1].Amount != 100 {
+		t.Fatalf("子委托错误: %+v", p.Children)
+	}
+}
+
+func TestAmbiguousChildReconciled(t *testing.T) {
+	for _, processed := range []bool{true, false} {
+		s, c := newTestClient(t)
+		x := NewExecutor(c, fixedQuote, nil)
+		var mu sync.Mutex
+		filled := make(map[string]bool)
+		x.OnProgress(func(p Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, child := range p.Children {
+				if child.OrderId == "" || filled[child.OrderId] || child.Status != model.OrderStatusReported {
+					continue
+				}
+				filled[child.OrderId] = true
+				if err := s.Fill(child.OrderId, child.Amount, decimal.RequireFromString("3.855")); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+		// 子委托的响应丢失，委托列表延迟更新，提交时无法确定结果
+		s.DropSubmits(1, processed)
+		s.DelayOrders(3)
+		p, err := x.Execute(context.Background(), ParentOrder{
+			Code:         "510300",
+			TradeType:    model.TradeTypeBuy,
+			Amount:       200,
+			Schedule:     []Slice{{At: time.Now(), Amount: 200}},
+			RepriceAfter: time.Minute,
+			PollInterval: 20 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("processed=%v: %v", processed, err)
+		}
+		if !p.Done || p.FilledAmount != 200 || len(p.Children) != 1 || p.Children[0].OrderId == "" {
+			t.Fatalf("processed=%v 进度错误: %+v", processed, p)
+		}
+		orders, err := c.GetOrdersList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(orders) != 1 || orders[0].OrderId != p.Children[0].OrderId {
+			t.Fatalf("processed=%v 子委托重复或者没有找回: %+v", processed, orders)
+		}
+	}
+}
+
+func TestAbortRevokesAmbiguousChild(t *testing.T) {
+	s, c := newTestClient(t)
+	x := NewExecutor(c, fixedQuote, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	x.OnProgress(func(p Progress) {
+		// 子委托结果未知时撤销母单
+		if len(p.Children) == 1 && p.Children[0].OrderId == "" {
+			cancel()
+		}
+	})
+	s.DropSubmits(1, true)
+	s.DelayOrders(3)
+	p, err := x.Execute(ctx, ParentOrder{
+		Code:         "510300",
+		TradeType:    model.TradeTypeBuy,
+		Amount:       200,
+		Schedule:     []Slice{{At: time.Now(), Amount: 200}},
+		RepriceAfter: time.Minute,
+		PollInterval: 20 * time.Millisecond,
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("应该返回 context.Canceled，实际为 %v", err)
+	}
+	if len(p.Children) != 1 || p.Children[0].OrderId == "" || p.Children[0].Status != model.OrderStatusRevoked || p.WorkingAmount != 0 {
+		t.Fatalf("结果未知的子委托应该被找回并撤单: %+v", p)
+	}
+	orders, err := c.GetOrdersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].OrderStatus() != model.OrderStatusRevoked {
+		t.Fatalf("委托应该已撤: %+v", orders)
+	}
+}
+
+func TestVWAPUsesKlineFunc(t *testing.T) {
+	var queried []string
+	kline := func(ctx context.Context, q model.QueryKlineParam) ([]*model.Kline, error) {
+		queried = append(queried, q.Code)
+		return []*model.Kline{
+			{Date: "2023-11-20 09:35", Volume: decimal.NewFromInt(300)},
+			{Date: "2023-11-20 09:40", Volume: decimal.NewFromInt(100)},
+			{Date: "2023-11-20 09:45", Volume: decimal.NewFromInt(100)},
+		}, nil
+	}
+	x := NewExecutor(nil, fixedQuote, kline)
+	p := ParentOrder{
+		Code:      "510300",
+		TradeType: model.TradeTypeBuy,
+		Amount:    1000,
+		Start:     time.Date(2023, 11, 21, 9, 30, 0, 0, cst),
+		End:       time.Date(2023, 11, 21, 9, 45, 0, 0, cst),
+		Algorithm: VWAP,
+		Slices:    3,
+	}
+	slices, err := x.prepare(context.Background(), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queried) != 1 || queried[0] != "510300" {
+		t.Fatalf("应该通过 kline 查询K线，实际查询了 %v", queried)
+	}
+	if len(p.Profile) != 3 || len(slices) != 3 {
+		t.Fatalf("成交量分布或拆分计划错误: %v %v", p.Profile, slices)
+	}
+	if slices[0].Amount != 600 {
+		t.Fatalf("第一个子委托应该为600，实际为 %d", slices[0].Amount)
+	}
+}
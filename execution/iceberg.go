@@ -0,0 +1,483 @@
+package execution
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	em_errors "github.com/yfjiang-danny/eastmoneyapi/errors"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+	"github.com/yfjiang-danny/eastmoneyapi/risk"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// 子委托提交失败（网络错误、会话失效等）后重试的间隔
+const defaultRetryInterval = 3 * time.Second
+
+// 撤单后查询子委托最终状态的默认间隔
+const defaultFinalPollInterval = time.Second
+
+// 撤单失败（不包括已经不可撤单）的最大次数，超过后冰山委托结束并返回最后一次的错误
+const maxRevokeFailures = 5
+
+// IcebergTrader 提交、撤销和查询委托，*client.EastMoneyClient 实现了该接口
+type IcebergTrader interface {
+	SubmitTradeContext(ctx context.Context, order model.TradeOrderForm) (string, error)
+	FindClientOrderContext(ctx context.Context, clientOrderId string) (string, error)
+	RevokeByOrderIdContext(ctx context.Context, orderId string) (model.RevokeResult, error)
+	GetOrdersListContext(ctx context.Context) ([]*model.Order, error)
+}
+
+// Tracker 委托状态的事件来源，*client.OrderTracker 实现了该接口。OnEvent 返回取消注册的函数
+type Tracker interface {
+	Track(orderIds ...string)
+	OnEvent(fn func(client.OrderEvent)) (remove func())
+}
+
+// IcebergOrder 冰山委托：同一时间最多只挂 DisplayLots 手，当前的子委托全部成交后再提交下一个
+type IcebergOrder struct {
+	Code      string
+	Name      string
+	TradeType model.TradeType
+	Amount    int
+	// 所有子委托的委托价格，即母单的限价
+	Price decimal.Decimal
+	// 每个子委托显示的手数
+	DisplayLots int
+	// 每手的数量，默认100
+	LotSize int
+	// 子委托提交失败后重试的间隔，默认3秒
+	RetryInterval time.Duration
+	// 撤单后查询子委托最终状态（以及撤单失败后重新撤单）的间隔，默认1秒
+	PollInterval time.Duration
+}
+
+// IcebergStatus 冰山委托的状态
+type IcebergStatus string
+
+const (
+	IcebergRunning   IcebergStatus = "running"
+	IcebergCompleted IcebergStatus = "completed"
+	IcebergCancelled IcebergStatus = "cancelled"
+	IcebergFailed    IcebergStatus = "failed"
+)
+
+// IcebergProgress 冰山委托的进度
+type IcebergProgress struct {
+	Status       IcebergStatus
+	Amount       int
+	FilledAmount int
+	AvgFillPrice decimal.Decimal
+	// 已提交的子委托个数和当前挂单的委托编号（没有挂单时为空）
+	Slices         int
+	CurrentOrderId string
+	Err            error
+}
+
+// Iceberg 执行中的冰山委托。
+// 子委托的状态由 Tracker 推送，会话失效重新登录不影响委托编号，因此重新登录后会继续跟踪；
+// 子委托因为网络错误或者会话失效提交失败时，会使用相同的客户端委托编号重试，不会重复委托。
+// 撤单后直接查询当日委托获取子委托的最终状态，Tracker 已经停止时也能结束；撤单失败时每次查询后重新撤单，
+// 连续失败 maxRevokeFailures 次后以 IcebergFailed 结束。等待重试提交时撤单，会先在当日委托中查找该子委托，找到则撤单
+type Iceberg struct {
+	trader      IcebergTrader
+	tracker     Tracker
+	removeEvent func()
+	order       IcebergOrder
+	id          string
+
+	events   chan client.OrderEvent
+	cancelCh chan struct{}
+	done     chan struct{}
+
+	mu sync.Mutex
+	// 已结束的子委托的成交
+	filledAmount int
+	filledValue  decimal.Decimal
+	// 当前子委托的数量和成交
+	current       string
+	currentAmount int
+	currentFilled int
+	currentValue  decimal.Decimal
+	slices        int
+	status        IcebergStatus
+	err           error
+	cancelOnce    sync.Once
+}
+
+// NewIceberg 创建冰山委托，调用 Start 后开始执行
+func NewIceberg(trader IcebergTrader, tracker Tracker, order IcebergOrder) (*Iceberg, error) {
+	if order.Code == "" || order.Amount <= 0 || order.DisplayLots <= 0 || !order.Price.IsPositive() {
+		return nil, errors.New("冰山委托的参数错误")
+	}
+	if order.TradeType != model.TradeTypeBuy && order.TradeType != model.TradeTypeSale {
+		return nil, errors.New("委托方向错误")
+	}
+	if order.LotSize <= 0 {
+		order.LotSize = defaultLotSize
+	}
+	if order.TradeType == model.TradeTypeBuy && order.Amount%order.LotSize != 0 {
+		return nil, errors.Errorf("买入数量必须为 %d 的整数倍", order.LotSize)
+	}
+	if order.RetryInterval <= 0 {
+		order.RetryInterval = defaultRetryInterval
+	}
+	if order.PollInterval <= 0 {
+		order.PollInterval = defaultFinalPollInterval
+	}
+	i := &Iceberg{
+		trader:   trader,
+		tracker:  tracker,
+		order:    order,
+		id:       "ice-" + strings.TrimPrefix(newId(), "algo-"),
+		events:   make(chan client.OrderEvent, 64),
+		cancelCh: make(chan struct{}),
+		done:     make(chan struct{}),
+		status:   IcebergRunning,
+	}
+	i.removeEvent = tracker.OnEvent(i.onEvent)
+	return i, nil
+}
+
+// Start 提交第一个子委托并开始执行，ctx 被取消时整体撤单
+func (i *Iceberg) Start(ctx context.Context) error {
+	if err := i.submitNext(ctx); err != nil {
+		i.finish(IcebergFailed, err)
+		return err
+	}
+	go i.run(ctx)
+	return nil
+}
+
+// Cancel 整体撤单：撤销当前的子委托，不再提交新的子委托，等待撤单完成或者 ctx 超时
+func (i *Iceberg) Cancel(ctx context.Context) error {
+	i.cancelOnce.Do(func() { close(i.cancelCh) })
+	select {
+	case <-i.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done 冰山委托结束（全部成交、撤单或者失败）后关闭
+func (i *Iceberg) Done() <-chan struct{} {
+	return i.done
+}
+
+// Progress 当前的进度
+func (i *Iceberg) Progress() IcebergProgress {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	filled := i.filledAmount + i.currentFilled
+	value := i.filledValue.Add(i.currentValue)
+	p := IcebergProgress{
+		Status:         i.status,
+		Amount:         i.order.Amount,
+		FilledAmount:   filled,
+		Slices:         i.slices,
+		CurrentOrderId: i.current,
+		Err:            i.err,
+	}
+	if filled > 0 {
+		p.AvgFillPrice = value.Div(decimal.NewFromInt(int64(filled)))
+	}
+	return p
+}
+
+// onEvent 在 Tracker 的协程中调用，只转发当前子委托的事件
+func (i *Iceberg) onEvent(ev client.OrderEvent) {
+	i.mu.Lock()
+	current := i.current
+	i.mu.Unlock()
+	if ev.OrderId != current || current == "" {
+		return
+	}
+	select {
+	case i.events <- ev:
+	case <-i.done:
+	}
+}
+
+func (i *Iceberg) run(ctx context.Context) {
+	var retry, poll <-chan time.Time
+	cancelCh := i.cancelCh
+	cancelling := false
+	// 撤单是否已经提交（或者已经不可撤单），以及连续失败的次数
+	revoked, revokeFailures := false, 0
+	// revoke 撤销当前的子委托，连续失败超过 maxRevokeFailures 次时结束并返回 true
+	revoke := func() bool {
+		err := i.revokeCurrent()
+		if err == nil {
+			revoked = true
+			return false
+		}
+		revokeFailures++
+		logrus.Warnf("冰山委托 %s 第 %d 次撤销子委托失败: %s", i.id, revokeFailures, err.Error())
+		if revokeFailures >= maxRevokeFailures {
+			i.finish(IcebergFailed, errors.Wrap(err, "撤销子委托失败"))
+			return true
+		}
+		return false
+	}
+	// cancel 撤销当前的子委托，返回是否已经结束
+	cancel := func() bool {
+		cancelling = true
+		if retry != nil {
+			// 等待重试提交的子委托可能已经到达券商，先在当日委托中查找
+			retry = nil
+			i.findPending()
+		}
+		if i.currentOrderId() == "" {
+			i.finish(IcebergCancelled, nil)
+			return true
+		}
+		if revoke() {
+			return true
+		}
+		// 不依赖 Tracker 推送，立即查询一次撤单的结果
+		poll = time.After(0)
+		return false
+	}
+	for {
+		select {
+		case ev := <-i.events:
+			if i.handle(ctx, ev, cancelling, &retry) {
+				return
+			}
+		case <-poll:
+			poll = nil
+			if ev, ok := i.queryFinal(); ok {
+				if i.handle(ctx, ev, cancelling, &retry) {
+					return
+				}
+			} else {
+				if !revoked && revoke() {
+					return
+				}
+				poll = time.After(i.order.PollInterval)
+			}
+		case <-retry:
+			retry = nil
+			if err := i.submitNext(ctx); err != nil {
+				if !retryable(err) {
+					i.finish(IcebergFailed, err)
+					return
+				}
+				logrus.Warnf("冰山委托 %s 提交子委托失败，稍后重试: %s", i.id, err.Error())
+				retry = time.After(i.order.RetryInterval)
+			}
+		case <-cancelCh:
+			cancelCh = nil
+			if !cancelling && cancel() {
+				return
+			}
+		case <-ctx.Done():
+			ctx = context.Background()
+			if !cancelling && cancel() {
+				return
+			}
+		}
+	}
+}
+
+// handle 处理当前子委托的事件，返回冰山委托是否已经结束。提交下一个子委托失败并且可以重试时设置 retry
+func (i *Iceberg) handle(ctx context.Context, ev client.OrderEvent, cancelling bool, retry *<-chan time.Time) bool {
+	i.mu.Lock()
+	i.currentFilled = ev.FilledAmount
+	i.currentValue = ev.AvgFillPrice.Mul(decimal.NewFromInt(int64(ev.FilledAmount)))
+	i.mu.Unlock()
+	switch ev.Type {
+	case client.OrderEventFilled, client.OrderEventCancelled, client.OrderEventRejected:
+	default:
+		return false
+	}
+	i.settle()
+	if ev.Type == client.OrderEventRejected {
+		i.finish(IcebergFailed, errors.Errorf("子委托 %s 为废单", ev.OrderId))
+		return true
+	}
+	if ev.Type == client.OrderEventCancelled && !cancelling {
+		i.finish(IcebergCancelled, errors.Errorf("子委托 %s 被撤销", ev.OrderId))
+		return true
+	}
+	if cancelling {
+		i.finish(IcebergCancelled, nil)
+		return true
+	}
+	if i.remaining() == 0 {
+		i.finish(IcebergCompleted, nil)
+		return true
+	}
+	if err := i.submitNext(ctx); err != nil {
+		if !retryable(err) {
+			i.finish(IcebergFailed, err)
+			return true
+		}
+		logrus.Warnf("冰山委托 %s 提交子委托失败，稍后重试: %s", i.id, err.Error())
+		*retry = time.After(i.order.RetryInterval)
+	}
+	return false
+}
+
+// queryFinal 查询当日委托，当前子委托已经结束时返回对应的事件
+func (i *Iceberg) queryFinal() (client.OrderEvent, bool) {
+	i.mu.Lock()
+	current := i.current
+	i.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	orders, err := i.trader.GetOrdersListContext(ctx)
+	if err != nil {
+		logrus.Warnf("冰山委托 %s 查询子委托 %s 失败: %s", i.id, current, err.Error())
+		return client.OrderEvent{}, false
+	}
+	for _, o := range orders {
+		if o.OrderId != current {
+			continue
+		}
+		ev := client.OrderEvent{
+			OrderId:      o.OrderId,
+			Status:       o.OrderStatus(),
+			FilledAmount: o.ClosingAmount(),
+			AvgFillPrice: o.ClosingPrice(),
+		}
+		switch ev.Status {
+		case model.OrderStatusFilled:
+			ev.Type = client.OrderEventFilled
+		case model.OrderStatusRevoked, model.OrderStatusPartFillRevoked:
+			ev.Type = client.OrderEventCancelled
+		case model.OrderStatusRejected:
+			ev.Type = client.OrderEventRejected
+		default:
+			return client.OrderEvent{}, false
+		}
+		return ev, true
+	}
+	return client.OrderEvent{}, false
+}
+
+// currentOrderId 当前挂单的委托编号，没有挂单时为空
+func (i *Iceberg) currentOrderId() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.current
+}
+
+// revokeCurrent 撤销当前的子委托。不在可撤单列表中说明已经成交或者撤销，
+// 最终的状态由 Tracker 推送或者查询当日委托获取，不返回错误
+func (i *Iceberg) revokeCurrent() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := i.trader.RevokeByOrderIdContext(ctx, i.currentOrderId())
+	if err != nil && !errors.Is(err, client.ErrOrderNotRevocable) {
+		return err
+	}
+	return nil
+}
+
+// nextSlice 下一个子委托的序号和数量，数量为显示的手数与剩余数量中较小的一个
+func (i *Iceberg) nextSlice() (seq, amount int) {
+	amount = i.order.DisplayLots * i.order.LotSize
+	if left := i.remaining(); left < amount {
+		amount = left
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.slices + 1, amount
+}
+
+// submitNext 提交下一个子委托
+func (i *Iceberg) submitNext(ctx context.Context) error {
+	seq, amount := i.nextSlice()
+	orderId, err := i.trader.SubmitTradeContext(ctx, model.TradeOrderForm{
+		Code:          i.order.Code,
+		Name:          i.order.Name,
+		Price:         i.order.Price,
+		Amount:        amount,
+		TradeType:     i.order.TradeType,
+		ClientOrderId: i.clientOrderId(seq),
+	})
+	if err != nil {
+		return err
+	}
+	i.setCurrent(seq, amount, orderId)
+	return nil
+}
+
+// findPending 在当日委托中查找等待重试提交的子委托，只查找，不会提交。找到后作为当前的子委托
+func (i *Iceberg) findPending() {
+	seq, amount := i.nextSlice()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	orderId, err := i.trader.FindClientOrderContext(ctx, i.clientOrderId(seq))
+	if err != nil {
+		logrus.Warnf("冰山委托 %s 查找子委托 %s 失败，请检查当日委托: %s", i.id, i.clientOrderId(seq), err.Error())
+		return
+	}
+	if orderId != "" {
+		i.setCurrent(seq, amount, orderId)
+	}
+}
+
+func (i *Iceberg) clientOrderId(seq int) string {
+	return i.id + "-" + strconv.Itoa(seq)
+}
+
+// setCurrent 记录新的子委托并开始跟踪
+func (i *Iceberg) setCurrent(seq, amount int, orderId string) {
+	i.mu.Lock()
+	i.slices = seq
+	i.current = orderId
+	i.currentAmount = amount
+	i.currentFilled, i.currentValue = 0, decimal.Zero
+	i.mu.Unlock()
+	i.tracker.Track(orderId)
+}
+
+// settle 当前子委托结束，累计其成交
+func (i *Iceberg) settle() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.filledAmount += i.currentFilled
+	i.filledValue = i.filledValue.Add(i.currentValue)
+	i.current, i.currentAmount, i.currentFilled, i.currentValue = "", 0, 0, decimal.Zero
+}
+
+// remaining 还没有委托的数量
+func (i *Iceberg) remaining() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.order.Amount - i.filledAmount - i.currentAmount
+}
+
+// finish 结束冰山委托并取消注册 Tracker 的回调
+func (i *Iceberg) finish(status IcebergStatus, err error) {
+	i.mu.Lock()
+	i.status, i.err = status, err
+	i.mu.Unlock()
+	close(i.done)
+	i.removeEvent()
+}
+
+// retryable 子委托提交失败后是否可以重试：券商返回的业务错误（资金不足、价格超出涨跌停等）、
+// 风控拒绝、数量超出可买可卖数量和登录失败都不重试，网络错误、会话失效和委托结果未知时重试
+func retryable(err error) bool {
+	var brokerErr *em_errors.BrokerError
+	var loginErr *client.LoginError
+	var exceeded *client.QuantityExceededError
+	switch {
+	case errors.Is(err, client.ErrOrderStatusUnknown), errors.Is(err, client.ErrSessionExpired):
+		return true
+	case errors.As(err, &brokerErr), errors.As(err, &loginErr), errors.As(err, &exceeded),
+		errors.Is(err, risk.ErrRejected), errors.Is(err, client.ErrClientClosed):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,343 @@
+package execution
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/client"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// countingTracker 记录还没有取消注册的回调个数
+type countingTracker struct {
+	Tracker
+	mu     sync.Mutex
+	active int
+}
+
+func (t *countingTracker) OnEvent(fn func(client.OrderEvent)) func() {
+	t.mu.Lock()
+	t.active++
+	t.mu.Unlock()
+	remove := t.Tracker.OnEvent(fn)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			t.active--
+			t.mu.Unlock()
+			remove()
+		})
+	}
+}
+
+func (t *countingTracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active
+}
+
+// silentTracker 不推送任何事件，相当于已经停止的 Tracker
+type silentTracker struct{}
+
+func (silentTracker) Track(orderIds ...string) {}
+
+func (silentTracker) OnEvent(fn func(client.OrderEvent)) func() { return func() {} }
+
+// flakyTrader 模拟子委托提交结果未知和撤单失败
+type flakyTrader struct {
+	*client.EastMoneyClient
+	mu sync.Mutex
+	// 之后的 ambiguous 次提交返回结果未知，processed 表示委托是否已经提交
+	ambiguous int
+	processed bool
+	// 之后的 revokeFailures 次撤单失败，revokes 为撤单的次数
+	revokeFailures int
+	revokes        int
+}
+
+func (f *flakyTrader) SubmitTradeContext(ctx context.Context, order model.TradeOrderForm) (string, error) {
+	f.mu.Lock()
+	ambiguous := f.ambiguous > 0
+	if ambiguous {
+		f.ambiguous--
+	}
+	f.mu.Unlock()
+	if !ambiguous {
+		return f.EastMoneyClient.SubmitTradeContext(ctx, order)
+	}
+	if f.processed {
+		if _, err := f.EastMoneyClient.SubmitTradeContext(ctx, order); err != nil {
+			return "", err
+		}
+	}
+	return "", &client.AmbiguousSubmitError{ClientOrderId: order.ClientOrderId, Err: errors.New("请求超时")}
+}
+
+func (f *flakyTrader) RevokeByOrderIdContext(ctx context.Context, orderId string) (model.RevokeResult, error) {
+	f.mu.Lock()
+	f.revokes++
+	fail := f.revokeFailures > 0
+	if fail {
+		f.revokeFailures--
+	}
+	f.mu.Unlock()
+	if fail {
+		return model.RevokeResult{}, errors.New("网络错误")
+	}
+	return f.EastMoneyClient.RevokeByOrderIdContext(ctx, orderId)
+}
+
+func (f *flakyTrader) Revokes() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.revokes
+}
+
+func findOrder(t *testing.T, c *client.EastMoneyClient, orderId string) *model.Order {
+	t.Helper()
+	orders, err := c.GetOrdersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range orders {
+		if o.OrderId == orderId {
+			return o
+		}
+	}
+	return nil
+}
+
+func icebergBuy() IcebergOrder {
+	return IcebergOrder{
+		Code:        "510300",
+		Name:        "沪深300ETF",
+		TradeType:   model.TradeTypeBuy,
+		Amount:      300,
+		Price:       decimal.RequireFromString("3.856"),
+		DisplayLots: 1,
+	}
+}
+
+func waitDone(t *testing.T, ice *Iceberg) {
+	t.Helper()
+	select {
+	case <-ice.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("冰山委托没有结束: %+v", ice.Progress())
+	}
+}
+
+func TestIcebergCompleted(t *testing.T) {
+	s, c := newTestClient(t)
+	tracker := &countingTracker{Tracker: client.NewOrderTracker(c, 20*time.Millisecond)}
+	tracker.Tracker.(*client.OrderTracker).Start(context.Background())
+	defer tracker.Tracker.(*client.OrderTracker).Stop()
+
+	ice, err := NewIceberg(c, tracker, icebergBuy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ice.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	filled := make(map[string]bool)
+	deadline := time.After(5 * time.Second)
+	for len(filled) < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("子委托没有依次提交: %+v", ice.Progress())
+		case <-time.After(10 * time.Millisecond):
+		}
+		if id := ice.Progress().CurrentOrderId; id != "" && !filled[id] {
+			filled[id] = true
+			if err := s.Fill(id, 100, decimal.RequireFromString("3.856")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	waitDone(t, ice)
+	p := ice.Progress()
+	if p.Status != IcebergCompleted || p.FilledAmount != 300 || p.Slices != 3 {
+		t.Fatalf("进度错误: %+v", p)
+	}
+	if tracker.Active() != 0 {
+		t.Fatal("结束后没有取消注册回调")
+	}
+}
+
+func TestIcebergCancelWithoutTrackerEvents(t *testing.T) {
+	s, c := newTestClient(t)
+	tracker := &countingTracker{Tracker: silentTracker{}}
+	ice, err := NewIceberg(c, tracker, icebergBuy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ice.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fill(ice.Progress().CurrentOrderId, 40, decimal.RequireFromString("3.855")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ice.Cancel(ctx); err != nil {
+		t.Fatalf("没有 Tracker 事件时撤单应该通过查询结束，实际为 %v", err)
+	}
+	p := ice.Progress()
+	if p.Status != IcebergCancelled || p.Err != nil || p.FilledAmount != 40 || p.CurrentOrderId != "" {
+		t.Fatalf("进度错误: %+v", p)
+	}
+	if !p.AvgFillPrice.Equal(decimal.RequireFromString("3.855")) {
+		t.Fatalf("成交均价错误: %s", p.AvgFillPrice)
+	}
+	if tracker.Active() != 0 {
+		t.Fatal("结束后没有取消注册回调")
+	}
+}
+
+func TestIcebergContextDone(t *testing.T) {
+	_, c := newTestClient(t)
+	ice, err := NewIceberg(c, silentTracker{}, icebergBuy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := ice.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	waitDone(t, ice)
+	if p := ice.Progress(); p.Status != IcebergCancelled || p.FilledAmount != 0 {
+		t.Fatalf("进度错误: %+v", p)
+	}
+}
+
+func TestIcebergRevokeRetried(t *testing.T) {
+	_, c := newTestClient(t)
+	trader := &flakyTrader{EastMoneyClient: c, revokeFailures: 2}
+	order := icebergBuy()
+	order.PollInterval = 10 * time.Millisecond
+	ice, err := NewIceberg(trader, silentTracker{}, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ice.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	orderId := ice.Progress().CurrentOrderId
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ice.Cancel(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p := ice.Progress(); p.Status != IcebergCancelled || p.Err != nil {
+		t.Fatalf("撤单失败后应该重新撤单: %+v", p)
+	}
+	if trader.Revokes() != 3 {
+		t.Fatalf("应该撤单3次，实际为 %d", trader.Revokes())
+	}
+	if o := findOrder(t, c, orderId); o == nil || o.OrderStatus() != model.OrderStatusRevoked {
+		t.Fatalf("子委托应该已撤: %+v", o)
+	}
+}
+
+func TestIcebergRevokeFailed(t *testing.T) {
+	_, c := newTestClient(t)
+	trader := &flakyTrader{EastMoneyClient: c, revokeFailures: 100}
+	order := icebergBuy()
+	order.PollInterval = 10 * time.Millisecond
+	ice, err := NewIceberg(trader, silentTracker{}, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ice.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ice.Cancel(ctx); err != nil {
+		t.Fatalf("多次撤单失败后应该结束，实际为 %v", err)
+	}
+	if p := ice.Progress(); p.Status != IcebergFailed || p.Err == nil {
+		t.Fatalf("多次撤单失败后应该为 failed: %+v", p)
+	}
+	if trader.Revokes() != maxRevokeFailures {
+		t.Fatalf("应该撤单 %d 次，实际为 %d", maxRevokeFailures, trader.Revokes())
+	}
+}
+
+func TestIcebergCancelPendingSubmit(t *testing.T) {
+	for _, processed := range []bool{true, false} {
+		s, c := newTestClient(t)
+		tracker := client.NewOrderTracker(c, 10*time.Millisecond)
+		tracker.Start(context.Background())
+		trader := &flakyTrader{EastMoneyClient: c, processed: processed}
+		order := icebergBuy()
+		order.RetryInterval = time.Minute
+		order.PollInterval = 10 * time.Millisecond
+		ice, err := NewIceberg(trader, tracker, order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ice.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		// 第一个子委托全部成交后，第二个子委托提交结果未知，等待重试
+		trader.mu.Lock()
+		trader.ambiguous = 1
+		trader.mu.Unlock()
+		first := ice.Progress().CurrentOrderId
+		if err := s.Fill(first, 100, decimal.RequireFromString("3.856")); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			trader.mu.Lock()
+			pending := trader.ambiguous == 0
+			trader.mu.Unlock()
+			if p := ice.Progress(); pending && p.FilledAmount == 100 && p.CurrentOrderId == "" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("processed=%v 第二个子委托没有提交: %+v", processed, ice.Progress())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := ice.Cancel(ctx); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		tracker.Stop()
+		p := ice.Progress()
+		if p.Status != IcebergCancelled || p.FilledAmount != 100 {
+			t.Fatalf("processed=%v 进度错误: %+v", processed, p)
+		}
+		orders, err := c.GetOrdersList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !processed {
+			if len(orders) != 1 || p.Slices != 1 {
+				t.Fatalf("没有提交的子委托不应该出现: %+v", orders)
+			}
+			continue
+		}
+		// 已经提交的子委托应该被找到并撤单
+		if len(orders) != 2 || p.Slices != 2 {
+			t.Fatalf("应该有两个子委托: %+v %+v", orders, p)
+		}
+		for _, o := range orders {
+			if o.OrderId != first && o.OrderStatus() != model.OrderStatusRevoked {
+				t.Fatalf("结果未知的子委托应该被撤单: %+v", o)
+			}
+		}
+	}
+}
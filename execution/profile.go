@@ -0,0 +1,81 @@
+package execution
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/api"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Bucket 日内某一分钟的成交量占比
+type Bucket struct {
+	Time   string          // 分钟，如 09:31
+	Weight decimal.Decimal // 占全天成交量的比例，所有 Bucket 的合计为1
+}
+
+// Profile 日内成交量分布，按时间排序
+type Profile []Bucket
+
+// BuildVolumeProfile 根据分钟K线计算日内成交量分布，K线的日期格式为 "2006-01-02 15:04"。
+// 多个交易日的K线按分钟合并，即计算多日的平均分布
+func BuildVolumeProfile(klines []*model.Kline) (Profile, error) {
+	volumes := make(map[string]decimal.Decimal)
+	total := decimal.Zero
+	for _, k := range klines {
+		idx := strings.LastIndex(k.Date, " ")
+		if idx < 0 {
+			return nil, errors.Errorf("不是分钟K线: %s", k.Date)
+		}
+		minute := k.Date[idx+1:]
+		volumes[minute] = volumes[minute].Add(k.Volume)
+		total = total.Add(k.Volume)
+	}
+	if !total.IsPositive() {
+		return nil, errors.New("K线的成交量为0，无法计算成交量分布")
+	}
+	profile := make(Profile, 0, len(volumes))
+	for minute, v := range volumes {
+		profile = append(profile, Bucket{Time: minute, Weight: v.Div(total)})
+	}
+	sort.Slice(profile, func(i, j int) bool { return profile[i].Time < profile[j].Time })
+	return profile, nil
+}
+
+// KlineFunc 查询K线，可以使用 api.Client 的 GetKlineContext 指向其他的行情地址
+type KlineFunc func(ctx context.Context, q model.QueryKlineParam) ([]*model.Kline, error)
+
+// LoadVolumeProfile 通过 kline 查询分钟K线并计算日内成交量分布，kline 为空时使用 api.GetKlineContext。
+// klineType 为 model.OneMinuteKlineType 时只有最近一个交易日的数据，model.FiveMinuteKlineType 可以获取多日的数据
+func LoadVolumeProfile(ctx context.Context, kline KlineFunc, code string, klineType model.KlineType) (Profile, error) {
+	if kline == nil {
+		kline = api.GetKlineContext
+	}
+	klines, err := kline(ctx, model.QueryKlineParam{
+		Code:  code,
+		Begin: time.Now().AddDate(0, 0, -10).Format("20060102"),
+		Type:  klineType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return BuildVolumeProfile(klines)
+}
+
+// weight 时间段 [from, to) 内的成交量占比，按分钟（HH:MM）比较。
+// 分钟K线的时间为该分钟的结束时间，如 09:31 表示 09:30-09:31 的成交
+func (p Profile) weight(from, to time.Time) decimal.Decimal {
+	begin, end := from.In(cst).Format("15:04"), to.In(cst).Format("15:04")
+	total := decimal.Zero
+	for _, b := range p {
+		if b.Time > begin && b.Time <= end {
+			total = total.Add(b.Weight)
+		}
+	}
+	return total
+}
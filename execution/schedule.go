@@ -0,0 +1,94 @@
+package execution
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// 东财的行情和交易时间都是北京时间
+var cst = time.FixedZone("CST", 8*3600)
+
+// Slice 拆分后的一个子委托：到达 At 时累计委托数量应达到之前所有 Slice 的 Amount 之和
+type Slice struct {
+	At     time.Time
+	Amount int
+}
+
+// isTradingMinute 是否为连续竞价时间 09:30-11:30、13:00-15:00
+func isTradingMinute(t time.Time) bool {
+	hm := t.In(cst).Format("15:04")
+	return (hm >= "09:30" && hm < "11:30") || (hm >= "13:00" && hm < "15:00")
+}
+
+// tradingMinutes 时间段 [start, end) 内的所有交易分钟
+func tradingMinutes(start, end time.Time) []time.Time {
+	var minutes []time.Time
+	for t := start.Truncate(time.Minute); t.Before(end); t = t.Add(time.Minute) {
+		if isTradingMinute(t) {
+			minutes = append(minutes, t)
+		}
+	}
+	return minutes
+}
+
+// ScheduleTWAP 在时间段内的交易时间上平均拆分为 slices 个子委托，跳过午间休市
+func ScheduleTWAP(amount, slices, lotSize int, start, end time.Time) ([]Slice, error) {
+	return schedule(amount, slices, lotSize, start, end, nil)
+}
+
+// ScheduleVWAP 按照日内成交量分布拆分为 slices 个子委托，时间段内成交量分布都为0时平均拆分
+func ScheduleVWAP(amount, slices, lotSize int, start, end time.Time, profile Profile) ([]Slice, error) {
+	if len(profile) == 0 {
+		return nil, errors.New("成交量分布为空")
+	}
+	return schedule(amount, slices, lotSize, start, end, profile)
+}
+
+// schedule 将交易分钟平均分为 slices 组，每组的权重为组内分钟的成交量占比（profile 为空时每分钟相同），
+// 按累计权重计算每组结束时应完成的累计数量，取整到 lotSize，最后一组补齐剩余数量
+func schedule(amount, slices, lotSize int, start, end time.Time, profile Profile) ([]Slice, error) {
+	if amount <= 0 || slices <= 0 || lotSize <= 0 {
+		return nil, errors.New("拆单参数错误")
+	}
+	minutes := tradingMinutes(start, end)
+	if len(minutes) == 0 {
+		return nil, errors.New("时间段内没有交易时间")
+	}
+	if slices > len(minutes) {
+		slices = len(minutes)
+	}
+
+	weights := make([]decimal.Decimal, slices)
+	total := decimal.Zero
+	for i := 0; i < slices; i++ {
+		from := minutes[i*len(minutes)/slices]
+		to := minutes[(i+1)*len(minutes)/slices-1].Add(time.Minute)
+		if profile != nil {
+			weights[i] = profile.weight(from, to)
+		}
+		total = total.Add(weights[i])
+	}
+	if !total.IsPositive() {
+		for i := range weights {
+			weights[i] = decimal.NewFromInt(1)
+		}
+		total = decimal.NewFromInt(int64(slices))
+	}
+
+	result := make([]Slice, 0, slices)
+	cumWeight, sent := decimal.Zero, 0
+	for i := 0; i < slices; i++ {
+		cumWeight = cumWeight.Add(weights[i])
+		target := amount
+		if i < slices-1 {
+			target = int(decimal.NewFromInt(int64(amount)).Mul(cumWeight).Div(total).IntPart()) / lotSize * lotSize
+		}
+		if target > sent {
+			result = append(result, Slice{At: minutes[i*len(minutes)/slices], Amount: target - sent})
+			sent = target
+		}
+	}
+	return result, nil
+}
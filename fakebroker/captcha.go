@@ -0,0 +1,79 @@
+package fakebroker
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+)
+
+// 验证码图片的尺寸，与东财登录页的验证码相同
+const (
+	captchaWidth  = 80
+	captchaHeight = 30
+)
+
+// 5x7 点阵数字
+var digitGlyphs = [10][7]string{
+	{".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	{"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	{".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	{"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	{"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	{"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	{"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	{"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	{".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	{".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+}
+
+// RenderCaptcha 生成登录验证码图片（PNG）：浅色背景上4个位置、大小、颜色随机的数字，加上噪点和干扰线。
+// 模拟服务的 /Login/YZM 返回 Captcha() 对应的图片。图片与东财真实的验证码不同，只能用于测试
+func RenderCaptcha(code string, rnd *rand.Rand) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, captchaWidth, captchaHeight))
+	for y := 0; y < captchaHeight; y++ {
+		for x := 0; x < captchaWidth; x++ {
+			v := uint8(235 + rnd.Intn(21))
+			img.Set(x, y, color.RGBA{v, v, uint8(225 + rnd.Intn(31)), 0xff})
+		}
+	}
+
+	// 干扰线颜色较浅
+	for i := 0; i < 2; i++ {
+		c := color.RGBA{uint8(170 + rnd.Intn(50)), uint8(170 + rnd.Intn(50)), uint8(170 + rnd.Intn(50)), 0xff}
+		y0, y1 := rnd.Intn(captchaHeight), rnd.Intn(captchaHeight)
+		for x := 0; x < captchaWidth; x++ {
+			img.Set(x, y0+(y1-y0)*x/captchaWidth, c)
+		}
+	}
+
+	x := 4 + rnd.Intn(5)
+	for _, ch := range code {
+		if ch < '0' || ch > '9' {
+			continue
+		}
+		glyph := digitGlyphs[ch-'0']
+		w, h := 11+rnd.Intn(4), 17+rnd.Intn(5)
+		y := rnd.Intn(captchaHeight - h + 1)
+		c := color.RGBA{uint8(rnd.Intn(100)), uint8(rnd.Intn(100)), uint8(rnd.Intn(120)), 0xff}
+		for gy := 0; gy < h; gy++ {
+			for gx := 0; gx < w; gx++ {
+				if glyph[gy*7/h][gx*5/w] == '#' {
+					img.Set(x+gx, y+gy, c)
+				}
+			}
+		}
+		x += w + 4 + rnd.Intn(4)
+	}
+
+	// 孤立的深色噪点
+	for i := 0; i < 30; i++ {
+		v := uint8(rnd.Intn(120))
+		img.Set(rnd.Intn(captchaWidth), rnd.Intn(captchaHeight), color.RGBA{v, v, v, 0xff})
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
@@ -0,0 +1,925 @@
+// Package fakebroker 基于 httptest 的东财网页版模拟服务，用于离线测试交易客户端
+// 账户、持仓和委托都保存在内存中，不会自动撮合，需要通过 Fill 模拟成交
+package fakebroker
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	math_rand "math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const sessionCookie = "fakebroker_session"
+
+// 会话失效时返回的消息
+const sessionExpiredMessage = "会话已超时，请重新登录!"
+
+// 委托状态
+const (
+	statusReported        = "已报"
+	statusPartFilled      = "部成"
+	statusFilled          = "已成"
+	statusRevoked         = "已撤"
+	statusPartFillRevoked = "部撤"
+	statusRejected        = "废单"
+)
+
+// Account 模拟账户
+type Account struct {
+	Id        string
+	Cash      decimal.Decimal // 可用资金
+	Frozen    decimal.Decimal // 冻结资金
+	Positions map[string]*Position
+	// 资金流水，交割单为其中有成交编号的部分
+	flows []map[string]string
+}
+
+// Position 模拟持仓
+type Position struct {
+	Code      string
+	Name      string
+	Quantity  int
+	Available int
+	Frozen    int
+	CostPrice decimal.Decimal
+	// 最新价，为0时使用成本价，成交后更新为成交价
+	LastPrice decimal.Decimal
+}
+
+// Order 模拟委托
+type Order struct {
+	Account      string
+	OrderId      string
+	Date         string
+	Time         string
+	Code         string
+	Name         string
+	TradeType    string // B 买入 S 卖出
+	Price        decimal.Decimal
+	Amount       int
+	FilledAmount int
+	FilledValue  decimal.Decimal
+	Status       string
+	deals        []*deal
+	// 之后的 hiddenQueries 次当日委托查询中不显示该委托
+	hiddenQueries int
+}
+
+type deal struct {
+	dealId string
+	time   string
+	price  decimal.Decimal
+	amount int
+}
+
+type session struct {
+	account     string
+	validateKey string
+}
+
+// Server 模拟的东财网页版服务
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	captcha  string
+	rnd      *math_rand.Rand
+	accounts map[string]*Account
+	sessions map[string]*session
+	orders   []*Order
+	seq      int
+	// 模拟提交委托超时：dropSubmits 次委托请求不返回响应而直接断开连接，dropProcessed 表示断开前是否已经生成了委托
+	dropSubmits   int
+	dropProcessed bool
+	// 模拟柜台延迟：新委托在之后的 delayOrders 次当日委托查询中不显示
+	delayOrders int
+}
+
+// New 创建并启动模拟服务，登录验证码固定为 Captcha() 的返回值，图片由 RenderCaptcha 生成
+// 密码在客户端使用RSA公钥加密，模拟服务无法解密，因此只校验账号和验证码
+func New() *Server {
+	s := &Server{
+		captcha:  "1234",
+		rnd:      math_rand.New(math_rand.NewSource(time.Now().UnixNano())),
+		accounts: make(map[string]*Account),
+		sessions: make(map[string]*session),
+		seq:      100000,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Login/YZM", s.handleYZM)
+	mux.HandleFunc("/Login/Authentication", s.handleAuthentication)
+	mux.HandleFunc("/Login", s.handleLoginPage)
+	mux.HandleFunc("/Login/ExitLogin", s.handleExitLogin)
+	mux.HandleFunc("/Search/Position", s.handlePositionPage)
+	mux.HandleFunc("/Trade/SubmitTradeV2", s.auth(s.handleSubmitTrade))
+	mux.HandleFunc("/Trade/RevokeOrders", s.auth(s.handleRevokeOrders))
+	mux.HandleFunc("/Trade/GetRevokeList", s.auth(s.handleRevokeList))
+	mux.HandleFunc("/Search/GetOrdersData", s.auth(s.handleOrdersData))
+	mux.HandleFunc("/Search/GetDealData", s.auth(s.handleDealData))
+	mux.HandleFunc("/Search/GetHisOrdersData", s.auth(s.handleHisOrdersData))
+	mux.HandleFunc("/Search/GetHisDealData", s.auth(s.handleHisDealData))
+	mux.HandleFunc("/Search/GetFundsFlow", s.auth(s.handleFundsFlow))
+	mux.HandleFunc("/Search/GetDeliveryData", s.auth(s.handleDeliveryData))
+	mux.HandleFunc("/Search/GetStockList", s.auth(s.handleStockList))
+	mux.HandleFunc("/Com/queryAssetAndPositionV1", s.auth(s.handleQueryAssetAndPosition))
+	mux.HandleFunc("/Trade/GetKyzjAndKml", s.auth(s.handleKyzjAndKml))
+	mux.HandleFunc("/Trade/GetCanBuyNewStockListV3", s.auth(s.handleCanBuyNewStockList))
+	mux.HandleFunc("/Trade/GetConvertibleBondListV2", s.auth(s.handleConvertibleBondList))
+	mux.HandleFunc("/Trade/SubmitBatTradeV2", s.auth(s.handleSubmitBatTrade))
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Captcha 登录验证码
+func (s *Server) Captcha() string {
+	return s.captcha
+}
+
+// AddAccount 添加资金账户
+func (s *Server) AddAccount(id string, cash decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc := &Account{
+		Id:        id,
+		Cash:      cash,
+		Positions: make(map[string]*Position),
+	}
+	s.accounts[id] = acc
+	now := brokerNow()
+	acc.addFlow(map[string]string{
+		"Fsrq": now.Format("20060102"),
+		"Fssj": now.Format("150405"),
+		"Ywsm": "银行转证券",
+		"Fsje": cash.String(),
+	})
+}
+
+// addFlow 记录一笔资金流水，发生后的资金余额为可用资金与冻结资金之和，手续费都为0
+func (acc *Account) addFlow(m map[string]string) {
+	for _, k := range []string{"Wtbh", "Cjbh", "Zqdm", "Zqmc"} {
+		if _, ok := m[k]; !ok {
+			m[k] = ""
+		}
+	}
+	for _, k := range []string{"Cjsl", "Cjjg", "Cjje", "Sxf", "Yhs", "Ghf", "Qtfy"} {
+		if _, ok := m[k]; !ok {
+			m[k] = "0"
+		}
+	}
+	m["Zjye"] = acc.Cash.Add(acc.Frozen).StringFixed(2)
+	acc.flows = append(acc.flows, m)
+}
+
+// AddPosition 添加持仓，持仓全部可用
+func (s *Server) AddPosition(account, code, name string, quantity int, costPrice decimal.Decimal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, ok := s.accounts[account]
+	if !ok {
+		return errors.New("账户不存在: " + account)
+	}
+	acc.Positions[code] = &Position{
+		Code:      code,
+		Name:      name,
+		Quantity:  quantity,
+		Available: quantity,
+		CostPrice: costPrice,
+	}
+	return nil
+}
+
+// SetLastPrice 设置所有账户中该证券持仓的最新价，用于计算市值和浮动盈亏
+func (s *Server) SetLastPrice(code string, price decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, acc := range s.accounts {
+		if p, ok := acc.Positions[code]; ok {
+			p.LastPrice = price
+		}
+	}
+}
+
+// AddOrder 添加委托，用于模拟历史委托，Date 为空时使用当天的日期，FilledAmount 大于0时生成一笔对应的成交
+func (s *Server) AddOrder(o Order) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	o.OrderId = strconv.Itoa(s.seq)
+	if o.Date == "" {
+		o.Date = brokerNow().Format("20060102")
+	}
+	if o.Time == "" {
+		o.Time = "093000"
+	}
+	if o.Status == "" {
+		o.Status = statusReported
+	}
+	if o.FilledAmount > 0 {
+		price := o.Price
+		if o.FilledValue.IsZero() {
+			o.FilledValue = price.Mul(decimal.NewFromInt(int64(o.FilledAmount)))
+		} else {
+			price = o.FilledValue.Div(decimal.NewFromInt(int64(o.FilledAmount)))
+		}
+		s.seq++
+		o.deals = []*deal{{dealId: strconv.Itoa(s.seq), time: o.Time, price: price, amount: o.FilledAmount}}
+	}
+	s.orders = append(s.orders, &o)
+	return o.OrderId
+}
+
+// GetAccount 获取账户的副本
+func (s *Server) GetAccount(id string) (Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, ok := s.accounts[id]
+	if !ok {
+		return Account{}, false
+	}
+	cp := *acc
+	cp.Positions = make(map[string]*Position, len(acc.Positions))
+	for k, v := range acc.Positions {
+		p := *v
+		cp.Positions[k] = &p
+	}
+	return cp, true
+}
+
+// GetOrder 获取委托的副本
+func (s *Server) GetOrder(orderId string) (Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrder(orderId)
+	if o == nil {
+		return Order{}, false
+	}
+	return *o, true
+}
+
+// ExpireSessions 使所有会话失效，模拟东财的会话超时
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]*session)
+}
+
+// Fill 模拟委托成交，amount 不能超过剩余的委托数量
+func (s *Server) Fill(orderId string, amount int, price decimal.Decimal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrder(orderId)
+	if o == nil {
+		return errors.New("委托不存在: " + orderId)
+	}
+	if o.Status != statusReported && o.Status != statusPartFilled {
+		return errors.New("委托状态不允许成交: " + o.Status)
+	}
+	if amount <= 0 || amount > o.Amount-o.FilledAmount {
+		return errors.Errorf("成交数量错误: %d", amount)
+	}
+	acc := s.accounts[o.Account]
+	value := price.Mul(decimal.NewFromInt(int64(amount)))
+	switch o.TradeType {
+	case "B":
+		// 冻结资金按委托价计算，多冻结的部分退回
+		acc.Frozen = acc.Frozen.Sub(o.Price.Mul(decimal.NewFromInt(int64(amount))))
+		acc.Cash = acc.Cash.Add(o.Price.Mul(decimal.NewFromInt(int64(amount)))).Sub(value)
+		p, ok := acc.Positions[o.Code]
+		if !ok {
+			p = &Position{Code: o.Code, Name: o.Name}
+			acc.Positions[o.Code] = p
+		}
+		cost := p.CostPrice.Mul(decimal.NewFromInt(int64(p.Quantity))).Add(value)
+		p.Quantity += amount
+		p.CostPrice = cost.Div(decimal.NewFromInt(int64(p.Quantity))).Round(3)
+		p.LastPrice = price
+	case "S":
+		p := acc.Positions[o.Code]
+		p.LastPrice = price
+		p.Frozen -= amount
+		p.Quantity -= amount
+		acc.Cash = acc.Cash.Add(value)
+		if p.Quantity == 0 {
+			delete(acc.Positions, o.Code)
+		}
+	}
+	o.FilledAmount += amount
+	o.FilledValue = o.FilledValue.Add(value)
+	if o.FilledAmount == o.Amount {
+		o.Status = statusFilled
+	} else {
+		o.Status = statusPartFilled
+	}
+	s.seq++
+	d := &deal{
+		dealId: strconv.Itoa(s.seq),
+		time:   brokerNow().Format("150405"),
+		price:  price,
+		amount: amount,
+	}
+	o.deals = append(o.deals, d)
+
+	flow := map[string]string{
+		"Fsrq": o.Date,
+		"Fssj": d.time,
+		"Wtbh": o.OrderId,
+		"Cjbh": d.dealId,
+		"Zqdm": o.Code,
+		"Zqmc": o.Name,
+		"Cjsl": strconv.Itoa(amount),
+		"Cjjg": price.String(),
+		"Cjje": value.StringFixed(2),
+	}
+	if o.TradeType == "B" {
+		flow["Ywsm"] = "证券买入"
+		flow["Fsje"] = value.Neg().StringFixed(2)
+	} else {
+		flow["Ywsm"] = "证券卖出"
+		flow["Fsje"] = value.StringFixed(2)
+	}
+	acc.addFlow(flow)
+	return nil
+}
+
+func (s *Server) findOrder(orderId string) *Order {
+	for _, o := range s.orders {
+		if o.OrderId == orderId {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleYZM(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	img := RenderCaptcha(s.captcha, s.rnd)
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(img)
+}
+
+func (s *Server) handleAuthentication(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.PostForm.Get("identifyCode") != s.captcha {
+		writeJson(w, map[string]interface{}{"Status": -1, "Errcode": -1, "Message": "验证码错误"})
+		return
+	}
+	account := r.PostForm.Get("userId")
+	if _, ok := s.accounts[account]; !ok {
+		writeJson(w, map[string]interface{}{"Status": -1, "Errcode": -1, "Message": "资金账号或密码错误"})
+		return
+	}
+	token := randomHex(16)
+	s.sessions[token] = &session{account: account, validateKey: randomHex(16)}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: token, Path: "/"})
+	writeJson(w, map[string]interface{}{"Status": 0, "Errcode": 0, "Message": ""})
+}
+
+func (s *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(`<html><body><form id="form1" action="/Login/Authentication"></form></body></html>`))
+}
+
+func (s *Server) handleExitLogin(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		s.mu.Lock()
+		delete(s.sessions, c.Value)
+		s.mu.Unlock()
+	}
+	http.Redirect(w, r, "/Login", http.StatusFound)
+}
+
+// SessionCount 当前有效的会话数量
+func (s *Server) SessionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// handlePositionPage 持仓页面，客户端从中解析 validatekey，未登录时跳转到登录页
+func (s *Server) handlePositionPage(w http.ResponseWriter, r *http.Request) {
+	sess := s.session(r)
+	if sess == nil {
+		http.Redirect(w, r, "/Login", http.StatusFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<html><body><input type="hidden" id="em_validatekey" value="%s" /></body></html>`, sess.validateKey)
+}
+
+func (s *Server) session(r *http.Request) *session {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[c.Value]
+}
+
+// auth 校验会话和 validatekey，通过后在持有锁的情况下执行 handler
+func (s *Server) auth(h func(w http.ResponseWriter, r *http.Request, acc *Account)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sess := s.session(r)
+		if sess == nil || r.URL.Query().Get("validatekey") != sess.validateKey {
+			writeJson(w, map[string]interface{}{"Status": -2, "Errcode": -2, "Message": sessionExpiredMessage})
+			return
+		}
+		r.ParseForm()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		h(w, r, s.accounts[sess.account])
+	}
+}
+
+// DropSubmits 之后的 n 次提交委托不返回响应而直接断开连接，模拟网络超时。
+// processed 为 true 时委托已经生成（请求到达了券商），否则委托没有生成
+func (s *Server) DropSubmits(n int, processed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropSubmits = n
+	s.dropProcessed = processed
+}
+
+// DelayOrders 之后提交的委托在接下来的 n 次当日委托查询中不显示，模拟委托列表更新延迟
+func (s *Server) DelayOrders(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delayOrders = n
+}
+
+// dropConnection 直接断开连接，不返回任何响应
+func dropConnection(w http.ResponseWriter) {
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+func (s *Server) handleSubmitTrade(w http.ResponseWriter, r *http.Request, acc *Account) {
+	if s.dropSubmits > 0 && !s.dropProcessed {
+		s.dropSubmits--
+		dropConnection(w)
+		return
+	}
+	code := r.PostForm.Get("stockCode")
+	tradeType := r.PostForm.Get("tradeType")
+	amount, err := strconv.Atoi(r.PostForm.Get("amount"))
+	if err != nil || amount <= 0 {
+		writeError(w, "委托数量错误")
+		return
+	}
+	price, err := decimal.NewFromString(r.PostForm.Get("price"))
+	if err != nil || !price.IsPositive() {
+		writeError(w, "委托价格错误")
+		return
+	}
+	if code == "" {
+		writeError(w, "证券代码错误")
+		return
+	}
+
+	switch tradeType {
+	case "B":
+		need := price.Mul(decimal.NewFromInt(int64(amount)))
+		if acc.Cash.LessThan(need) {
+			writeError(w, "可用资金不足")
+			return
+		}
+		acc.Cash = acc.Cash.Sub(need)
+		acc.Frozen = acc.Frozen.Add(need)
+	case "S":
+		p, ok := acc.Positions[code]
+		if !ok || p.Available < amount {
+			writeError(w, "可用股份不足")
+			return
+		}
+		p.Available -= amount
+		p.Frozen += amount
+	default:
+		writeError(w, "委托方向错误")
+		return
+	}
+
+	now := brokerNow()
+	s.seq++
+	o := &Order{
+		Account:   acc.Id,
+		OrderId:   strconv.Itoa(s.seq),
+		Date:      now.Format("20060102"),
+		Time:      now.Format("150405"),
+		Code:      code,
+		Name:      r.PostForm.Get("zqmc"),
+		TradeType: tradeType,
+		Price:     price,
+		Amount:    amount,
+		Status:    statusReported,
+
+		hiddenQueries: s.delayOrders,
+	}
+	s.orders = append(s.orders, o)
+	if s.dropSubmits > 0 {
+		s.dropSubmits--
+		dropConnection(w)
+		return
+	}
+	writeJson(w, map[string]interface{}{
+		"Status":  0,
+		"Message": "",
+		"Data":    []map[string]string{{"Wtbh": o.OrderId}},
+	})
+}
+
+// handleRevokeOrders 撤单，返回 "委托编号: 消息"，多条之间使用三个空格分割
+func (s *Server) handleRevokeOrders(w http.ResponseWriter, r *http.Request, acc *Account) {
+	var msgs []string
+	for _, revoke := range strings.Split(r.PostForm.Get("revokes"), ",") {
+		parts := strings.SplitN(revoke, "_", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		orderId := parts[1]
+		o := s.findOrder(orderId)
+		switch {
+		case o == nil || o.Account != acc.Id || o.Date != parts[0]:
+			msgs = append(msgs, orderId+": 撤单失败，委托不存在")
+		case o.Status != statusReported && o.Status != statusPartFilled:
+			msgs = append(msgs, orderId+": 撤单失败，该委托已"+strings.TrimPrefix(o.Status, "已"))
+		default:
+			s.revoke(acc, o)
+			msgs = append(msgs, orderId+": 撤单委托已提交")
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(strings.Join(msgs, "   ")))
+}
+
+// Reject 模拟交易所拒绝委托（废单），委托没有成交时才能拒绝，冻结的资金或股份退回
+func (s *Server) Reject(orderId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrder(orderId)
+	if o == nil {
+		return errors.New("委托不存在: " + orderId)
+	}
+	if o.Status != statusReported {
+		return errors.New("委托状态不允许废单: " + o.Status)
+	}
+	s.revoke(s.accounts[o.Account], o)
+	o.Status = statusRejected
+	return nil
+}
+
+func (s *Server) revoke(acc *Account, o *Order) {
+	left := o.Amount - o.FilledAmount
+	switch o.TradeType {
+	case "B":
+		v := o.Price.Mul(decimal.NewFromInt(int64(left)))
+		acc.Frozen = acc.Frozen.Sub(v)
+		acc.Cash = acc.Cash.Add(v)
+	case "S":
+		p := acc.Positions[o.Code]
+		p.Frozen -= left
+		p.Available += left
+	}
+	if o.FilledAmount > 0 {
+		o.Status = statusPartFillRevoked
+	} else {
+		o.Status = statusRevoked
+	}
+}
+
+func (s *Server) handleOrdersData(w http.ResponseWriter, r *http.Request, acc *Account) {
+	today := brokerNow().Format("20060102")
+	s.writeOrders(w, r, acc, func(o *Order) bool {
+		if o.hiddenQueries > 0 {
+			o.hiddenQueries--
+			return false
+		}
+		return o.Date == today
+	})
+}
+
+func (s *Server) handleRevokeList(w http.ResponseWriter, r *http.Request, acc *Account) {
+	today := brokerNow().Format("20060102")
+	s.writeOrders(w, r, acc, func(o *Order) bool {
+		return o.Date == today && (o.Status == statusReported || o.Status == statusPartFilled)
+	})
+}
+
+func (s *Server) writeOrders(w http.ResponseWriter, r *http.Request, acc *Account, filter func(o *Order) bool) {
+	data := make([]map[string]string, 0)
+	for _, o := range s.orders {
+		if o.Account != acc.Id || !filter(o) {
+			continue
+		}
+		data = append(data, o.toJson())
+	}
+	writeJson(w, map[string]interface{}{"Status": 0, "Message": "", "Data": paginate(r, data)})
+}
+
+// paginate 按照东财的方式翻页：qqhs 为每页数量，dwc 为上一页最后一条数据的定位串
+func paginate(r *http.Request, data []map[string]string) []map[string]string {
+	for i := range data {
+		data[i]["Dwc"] = strconv.Itoa(i + 1)
+	}
+	start, _ := strconv.Atoi(r.PostForm.Get("dwc"))
+	if start > len(data) {
+		start = len(data)
+	}
+	data = data[start:]
+	if size, err := strconv.Atoi(r.PostForm.Get("qqhs")); err == nil && size > 0 && size < len(data) {
+		data = data[:size]
+	}
+	return data
+}
+
+// 历史查询的最大日期跨度
+const historyMaxDays = 30
+
+func (s *Server) handleHisOrdersData(w http.ResponseWriter, r *http.Request, acc *Account) {
+	inRange, ok := historyFilter(w, r)
+	if !ok {
+		return
+	}
+	s.writeOrders(w, r, acc, func(o *Order) bool { return inRange(o.Date) })
+}
+
+func (s *Server) handleHisDealData(w http.ResponseWriter, r *http.Request, acc *Account) {
+	inRange, ok := historyFilter(w, r)
+	if !ok {
+		return
+	}
+	s.writeDeals(w, r, acc, func(o *Order) bool { return inRange(o.Date) })
+}
+
+func (s *Server) handleFundsFlow(w http.ResponseWriter, r *http.Request, acc *Account) {
+	s.writeFlows(w, r, acc, false)
+}
+
+func (s *Server) handleDeliveryData(w http.ResponseWriter, r *http.Request, acc *Account) {
+	s.writeFlows(w, r, acc, true)
+}
+
+func (s *Server) writeFlows(w http.ResponseWriter, r *http.Request, acc *Account, dealOnly bool) {
+	inRange, ok := historyFilter(w, r)
+	if !ok {
+		return
+	}
+	data := make([]map[string]string, 0)
+	for _, f := range acc.flows {
+		if !inRange(f["Fsrq"]) || (dealOnly && f["Cjbh"] == "") {
+			continue
+		}
+		m := make(map[string]string, len(f))
+		for k, v := range f {
+			m[k] = v
+		}
+		data = append(data, m)
+	}
+	writeJson(w, map[string]interface{}{"Status": 0, "Message": "", "Data": paginate(r, data)})
+}
+
+// historyFilter 根据 st、et 过滤日期（20060102），日期跨度超过 historyMaxDays 时返回错误
+func historyFilter(w http.ResponseWriter, r *http.Request) (func(date string) bool, bool) {
+	st, err1 := time.Parse("2006-01-02", r.PostForm.Get("st"))
+	et, err2 := time.Parse("2006-01-02", r.PostForm.Get("et"))
+	if err1 != nil || err2 != nil || et.Before(st) {
+		writeError(w, "查询日期错误")
+		return nil, false
+	}
+	if et.Sub(st) >= historyMaxDays*24*time.Hour {
+		writeError(w, fmt.Sprintf("查询日期跨度不能超过%d天", historyMaxDays))
+		return nil, false
+	}
+	begin, end := st.Format("20060102"), et.Format("20060102")
+	return func(date string) bool {
+		return date >= begin && date <= end
+	}, true
+}
+
+func (s *Server) handleDealData(w http.ResponseWriter, r *http.Request, acc *Account) {
+	today := brokerNow().Format("20060102")
+	s.writeDeals(w, r, acc, func(o *Order) bool { return o.Date == today })
+}
+
+func (s *Server) writeDeals(w http.ResponseWriter, r *http.Request, acc *Account, filter func(o *Order) bool) {
+	data := make([]map[string]string, 0)
+	for _, o := range s.orders {
+		if o.Account != acc.Id || !filter(o) {
+			continue
+		}
+		for _, d := range o.deals {
+			m := o.toJson()
+			m["Cjbh"] = d.dealId
+			m["Cjsj"] = d.time
+			m["Cjjg"] = d.price.String()
+			m["Cjsl"] = strconv.Itoa(d.amount)
+			data = append(data, m)
+		}
+	}
+	writeJson(w, map[string]interface{}{"Status": 0, "Message": "", "Data": paginate(r, data)})
+}
+
+func (o *Order) toJson() map[string]string {
+	m := map[string]string{
+		"Wtrq": o.Date,
+		"Wtsj": o.Time,
+		"Wtbh": o.OrderId,
+		"Zqdm": o.Code,
+		"Zqmc": o.Name,
+		"Mmlb": o.TradeType,
+		"Wtzt": o.Status,
+		"Wtjg": o.Price.String(),
+		"Wtsl": strconv.Itoa(o.Amount),
+		"Cjsl": strconv.Itoa(o.FilledAmount),
+		"Cjjg": "0",
+		"Cjsj": "",
+		"Cjbh": "",
+	}
+	if o.TradeType == "B" {
+		m["Mmsm"] = "证券买入"
+	} else {
+		m["Mmsm"] = "证券卖出"
+	}
+	if o.FilledAmount > 0 {
+		m["Cjjg"] = o.FilledValue.Div(decimal.NewFromInt(int64(o.FilledAmount))).Round(3).String()
+		last := o.deals[len(o.deals)-1]
+		m["Cjsj"] = last.time
+	}
+	return m
+}
+
+func (s *Server) handleStockList(w http.ResponseWriter, r *http.Request, acc *Account) {
+	writeJson(w, map[string]interface{}{"Status": 0, "Message": "", "Data": paginate(r, positionsJson(acc))})
+}
+
+// handleKyzjAndKml 可买可卖数量，买入按整手计算（模拟服务没有交易费用），卖出为可用数量
+func (s *Server) handleKyzjAndKml(w http.ResponseWriter, r *http.Request, acc *Account) {
+	price, err := decimal.NewFromString(r.PostForm.Get("price"))
+	if err != nil || !price.IsPositive() {
+		writeError(w, "委托价格错误")
+		return
+	}
+	quantity := 0
+	switch r.PostForm.Get("tradeType") {
+	case "B":
+		quantity = int(acc.Cash.Div(price).IntPart()) / 100 * 100
+	case "S":
+		if p, ok := acc.Positions[r.PostForm.Get("stockCode")]; ok {
+			quantity = p.Available
+		}
+	default:
+		writeError(w, "委托方向错误")
+		return
+	}
+	writeJson(w, map[string]interface{}{
+		"Status":  0,
+		"Message": "",
+		"Data": map[string]string{
+			"Kyzj": acc.Cash.StringFixed(2),
+			"Kmml": strconv.Itoa(quantity),
+		},
+	})
+}
+
+func (s *Server) handleQueryAssetAndPosition(w http.ResponseWriter, r *http.Request, acc *Account) {
+	marketValue, profitLoss := decimal.Zero, decimal.Zero
+	for _, p := range acc.Positions {
+		marketValue = marketValue.Add(p.marketValue())
+		profitLoss = profitLoss.Add(p.profitLoss())
+	}
+	balance := acc.Cash.Add(acc.Frozen)
+	writeJson(w, map[string]interface{}{
+		"Status":  0,
+		"Message": "",
+		"Data": []map[string]interface{}{{
+			"Zzc":       balance.Add(marketValue).StringFixed(2),
+			"Zxsz":      marketValue.StringFixed(2),
+			"Kyzj":      acc.Cash.StringFixed(2),
+			"Kqzj":      acc.Cash.StringFixed(2),
+			"Djzj":      acc.Frozen.StringFixed(2),
+			"Zjye":      balance.StringFixed(2),
+			"Ljyk":      profitLoss.StringFixed(2),
+			"Dryk":      "0.00",
+			"positions": positionsJson(acc),
+		}},
+	})
+}
+
+func (p *Position) lastPrice() decimal.Decimal {
+	if p.LastPrice.IsZero() {
+		return p.CostPrice
+	}
+	return p.LastPrice
+}
+
+func (p *Position) marketValue() decimal.Decimal {
+	return p.lastPrice().Mul(decimal.NewFromInt(int64(p.Quantity)))
+}
+
+func (p *Position) profitLoss() decimal.Decimal {
+	return p.lastPrice().Sub(p.CostPrice).Mul(decimal.NewFromInt(int64(p.Quantity)))
+}
+
+// market 沪市代码以 5、6、9 开头，其余为深市
+func market(code string) string {
+	if strings.HasPrefix(code, "5") || strings.HasPrefix(code, "6") || strings.HasPrefix(code, "9") {
+		return "HA"
+	}
+	return "SA"
+}
+
+func positionsJson(acc *Account) []map[string]string {
+	codes := make([]string, 0, len(acc.Positions))
+	for code := range acc.Positions {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	data := make([]map[string]string, 0, len(codes))
+	for _, code := range codes {
+		p := acc.Positions[code]
+		ratio := decimal.Zero
+		if p.CostPrice.IsPositive() {
+			ratio = p.lastPrice().Sub(p.CostPrice).Div(p.CostPrice)
+		}
+		data = append(data, map[string]string{
+			"Zqdm":   p.Code,
+			"Zqmc":   p.Name,
+			"Kysl":   strconv.Itoa(p.Available),
+			"Zqsl":   strconv.Itoa(p.Quantity),
+			"Cbjg":   p.CostPrice.String(),
+			"Zxjg":   p.lastPrice().String(),
+			"Zxsz":   p.marketValue().StringFixed(2),
+			"Ljyk":   p.profitLoss().StringFixed(2),
+			"Ykbl":   ratio.StringFixed(4),
+			"Dryk":   "0.00",
+			"Market": market(p.Code),
+		})
+	}
+	return data
+}
+
+func (s *Server) handleCanBuyNewStockList(w http.ResponseWriter, r *http.Request, acc *Account) {
+	writeJson(w, map[string]interface{}{"NewQuota": []interface{}{}, "NewStockList": []interface{}{}})
+}
+
+func (s *Server) handleConvertibleBondList(w http.ResponseWriter, r *http.Request, acc *Account) {
+	writeJson(w, map[string]interface{}{"Status": 0, "Errcode": 0, "Message": "", "Data": []interface{}{}})
+}
+
+func (s *Server) handleSubmitBatTrade(w http.ResponseWriter, r *http.Request, acc *Account) {
+	var params []map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, "参数错误")
+		return
+	}
+	writeJson(w, map[string]interface{}{"Status": 0, "Message": "", "Data": []interface{}{}})
+}
+
+func writeError(w http.ResponseWriter, msg string) {
+	writeJson(w, map[string]interface{}{"Status": -1, "Errcode": -1, "Message": msg})
+}
+
+func writeJson(w http.ResponseWriter, v interface{}) {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(v)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// FixedRecognizer 返回固定验证码的识别器，配合 Captcha() 使用
+type FixedRecognizer string
+
+func (f FixedRecognizer) Recognize(ctx context.Context, img []byte) (string, error) {
+	return string(f), nil
+}
+
+// 东财返回的日期和时间都是北京时间
+var cst = time.FixedZone("CST", 8*3600)
+
+func brokerNow() time.Time {
+	return time.Now().In(cst)
+}
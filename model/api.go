@@ -52,9 +52,12 @@ func (s *Stockquote) GetActualPrice(magnification float64) {
 type KlineType string
 
 const (
-	DailyKlineType   KlineType = "101"
-	WeeklyKlineType  KlineType = "102"
-	MonthlyKlineType KlineType = "103"
+	// 1分钟K线只能获取最近一个交易日的数据
+	OneMinuteKlineType  KlineType = "1"
+	FiveMinuteKlineType KlineType = "5"
+	DailyKlineType      KlineType = "101"
+	WeeklyKlineType     KlineType = "102"
+	MonthlyKlineType    KlineType = "103"
 )
 
 // k线数据
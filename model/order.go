@@ -1,6 +1,10 @@
 package model
 
 import (
+	"encoding/json"
+	"strings"
+	"time"
+
 	"github.com/shopspring/decimal"
 )
 
@@ -14,6 +18,60 @@ type PositionDetail struct {
 	TotalQuantityStr string `json:"Zqsl"`
 	// 成本价（平均）
 	CostPriceStr string `json:"Cbjg"`
+	// 最新价
+	LatestPriceStr string `json:"Zxjg"`
+	// 最新市值
+	MarketValueStr string `json:"Zxsz"`
+	// 浮动盈亏
+	ProfitLossStr string `json:"Ljyk"`
+	// 盈亏比例
+	ProfitLossRatioStr string `json:"Ykbl"`
+	// 当日盈亏
+	TodayProfitLossStr string `json:"Dryk"`
+	// 交易市场，HA 沪A、SA 深A
+	Market string `json:"Market"`
+	// 定位串，用于翻页
+	Dwc string `json:"Dwc"`
+}
+
+// AvailableQuantity 可用数量
+func (p *PositionDetail) AvailableQuantity() int {
+	return parseInt(p.AvailableQuantityStr)
+}
+
+// TotalQuantity 持仓数量
+func (p *PositionDetail) TotalQuantity() int {
+	return parseInt(p.TotalQuantityStr)
+}
+
+// CostPrice 成本价
+func (p *PositionDetail) CostPrice() decimal.Decimal {
+	return parseDecimal(p.CostPriceStr)
+}
+
+// LatestPrice 最新价
+func (p *PositionDetail) LatestPrice() decimal.Decimal {
+	return parseDecimal(p.LatestPriceStr)
+}
+
+// MarketValue 最新市值
+func (p *PositionDetail) MarketValue() decimal.Decimal {
+	return parseDecimal(p.MarketValueStr)
+}
+
+// ProfitLoss 浮动盈亏
+func (p *PositionDetail) ProfitLoss() decimal.Decimal {
+	return parseDecimal(p.ProfitLossStr)
+}
+
+// ProfitLossRatio 盈亏比例
+func (p *PositionDetail) ProfitLossRatio() decimal.Decimal {
+	return parseDecimal(p.ProfitLossRatioStr)
+}
+
+// TodayProfitLoss 当日盈亏
+func (p *PositionDetail) TodayProfitLoss() decimal.Decimal {
+	return parseDecimal(p.TodayProfitLossStr)
 }
 
 // AccountDetail 账户详情
@@ -21,8 +79,60 @@ type AccountDetail struct {
 	// 总资产
 	TotalAssetStr string `json:"Zzc"`
 	// 可用资金
-	AvailableFundStr string           `json:"Kyzj"`
-	Positions        []PositionDetail `json:"positions"`
+	AvailableFundStr string `json:"Kyzj"`
+	// 资金余额
+	FundBalanceStr string `json:"Zjye"`
+	// 可取资金
+	WithdrawableFundStr string `json:"Kqzj"`
+	// 冻结资金
+	FrozenFundStr string `json:"Djzj"`
+	// 总市值
+	MarketValueStr string `json:"Zxsz"`
+	// 浮动盈亏
+	ProfitLossStr string `json:"Ljyk"`
+	// 当日盈亏
+	TodayProfitLossStr string           `json:"Dryk"`
+	Positions          []PositionDetail `json:"positions"`
+}
+
+// TotalAsset 总资产
+func (a *AccountDetail) TotalAsset() decimal.Decimal {
+	return parseDecimal(a.TotalAssetStr)
+}
+
+// AvailableFund 可用资金
+func (a *AccountDetail) AvailableFund() decimal.Decimal {
+	return parseDecimal(a.AvailableFundStr)
+}
+
+// FundBalance 资金余额
+func (a *AccountDetail) FundBalance() decimal.Decimal {
+	return parseDecimal(a.FundBalanceStr)
+}
+
+// WithdrawableFund 可取资金
+func (a *AccountDetail) WithdrawableFund() decimal.Decimal {
+	return parseDecimal(a.WithdrawableFundStr)
+}
+
+// FrozenFund 冻结资金
+func (a *AccountDetail) FrozenFund() decimal.Decimal {
+	return parseDecimal(a.FrozenFundStr)
+}
+
+// MarketValue 总市值
+func (a *AccountDetail) MarketValue() decimal.Decimal {
+	return parseDecimal(a.MarketValueStr)
+}
+
+// ProfitLoss 浮动盈亏
+func (a *AccountDetail) ProfitLoss() decimal.Decimal {
+	return parseDecimal(a.ProfitLossStr)
+}
+
+// TodayProfitLoss 当日盈亏
+func (a *AccountDetail) TodayProfitLoss() decimal.Decimal {
+	return parseDecimal(a.TodayProfitLossStr)
 }
 
 // TradeType 交易类型
@@ -41,6 +151,8 @@ type TradeOrderForm struct {
 	Price     decimal.Decimal //价格
 	Amount    int             // 数量
 	TradeType TradeType       // 交易类型
+	// 客户端委托编号，为空时 SubmitTrade 自动生成。使用相同的编号重新提交时不会重复委托
+	ClientOrderId string `json:"-"`
 }
 type Order struct {
 	Date             string `json:"Wtrq"` // 委托日期
@@ -56,4 +168,129 @@ type Order struct {
 	AmountStr        string `json:"Wtsl"` // 委托数量
 	ClosingPriceStr  string `json:"Cjjg"` // 成交价格
 	ClosingAmountStr string `json:"Cjsl"` // 成交数量
+	Dwc              string `json:"Dwc"`  // 定位串，用于翻页
+}
+
+// OrderStatus 委托状态
+type OrderStatus string
+
+const (
+	OrderStatusUnreported      OrderStatus = "未报"
+	OrderStatusReported        OrderStatus = "已报"
+	OrderStatusPartFilled      OrderStatus = "部成"
+	OrderStatusFilled          OrderStatus = "已成"
+	OrderStatusRevoked         OrderStatus = "已撤"
+	OrderStatusPartFillRevoked OrderStatus = "部撤"
+	OrderStatusRejected        OrderStatus = "废单"
+)
+
+// IsFinal 委托是否已经结束（全部成交、已撤、部撤或者废单），结束后不会再有新的成交
+func (s OrderStatus) IsFinal() bool {
+	switch s {
+	case OrderStatusFilled, OrderStatusRevoked, OrderStatusPartFillRevoked, OrderStatusRejected:
+		return true
+	}
+	return false
+}
+
+// IsRevocable 委托是否可以撤单
+func (s OrderStatus) IsRevocable() bool {
+	return s == OrderStatusUnreported || s == OrderStatusReported || s == OrderStatusPartFilled
+}
+
+// 东财返回的日期时间为北京时间
+var cst = time.FixedZone("CST", 8*3600)
+
+// OrderPrice 委托价格
+func (o *Order) OrderPrice() decimal.Decimal {
+	return parseDecimal(o.Price)
+}
+
+// Amount 委托数量
+func (o *Order) Amount() int {
+	return parseInt(o.AmountStr)
+}
+
+// ClosingPrice 成交价格
+func (o *Order) ClosingPrice() decimal.Decimal {
+	return parseDecimal(o.ClosingPriceStr)
+}
+
+// ClosingAmount 成交数量
+func (o *Order) ClosingAmount() int {
+	return parseInt(o.ClosingAmountStr)
+}
+
+// OrderStatus 委托状态
+func (o *Order) OrderStatus() OrderStatus {
+	return OrderStatus(strings.TrimSpace(o.Status))
+}
+
+// TradeType 委托方向，根据 Mmsm（如 证券买入、担保品卖出）判断，无法判断时返回空字符串
+func (o *Order) TradeType() TradeType {
+	switch {
+	case strings.Contains(o.Type, "买"):
+		return TradeTypeBuy
+	case strings.Contains(o.Type, "卖"):
+		return TradeTypeSale
+	}
+	return ""
+}
+
+// OrderTime 委托时间，由 Date 和 Time 组成，解析失败时返回零值
+func (o *Order) OrderTime() time.Time {
+	return parseDateTime(o.Date, o.Time)
+}
+
+// ClosingDateTime 成交时间，由 Date 和 ClosingTime 组成，没有成交时返回零值
+func (o *Order) ClosingDateTime() time.Time {
+	return parseDateTime(o.Date, o.ClosingTime)
+}
+
+// parseDateTime 解析东财返回的日期（20231121）和时间（093015，可能省略前导0）
+func parseDateTime(date, clock string) time.Time {
+	date, clock = strings.TrimSpace(date), strings.TrimSpace(clock)
+	if date == "" || clock == "" {
+		return time.Time{}
+	}
+	if len(clock) < 6 {
+		clock = strings.Repeat("0", 6-len(clock)) + clock
+	}
+	t, err := time.ParseInLocation("20060102150405", date+clock, cst)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// MaxTradeQuantity 最大可买/可卖数量
+type MaxTradeQuantity struct {
+	Code      string
+	TradeType TradeType
+	Price     decimal.Decimal
+	// 最大可买（按委托价格、可用资金并扣除费用计算）或可卖数量
+	Quantity int
+	// 可用资金
+	AvailableFund decimal.Decimal
+}
+
+// UnmarshalJSON 解析可买可卖接口返回的 Kmml（数量）和 Kyzj（可用资金），Code、TradeType、Price 由调用方填写
+func (m *MaxTradeQuantity) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Kmml string `json:"Kmml"`
+		Kyzj string `json:"Kyzj"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	m.Quantity = parseInt(raw.Kmml)
+	m.AvailableFund = parseDecimal(raw.Kyzj)
+	return nil
+}
+
+// RevokeResult 单个委托的撤单结果
+type RevokeResult struct {
+	OrderId string // 委托编号
+	Success bool   // 撤单委托是否提交成功
+	Message string // 东财返回的消息
 }
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// loadFixture 读取 testdata 下与东财接口格式相同的响应，并通过 Response 解析 Data
+func loadFixture(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.Bind(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrderAccessors(t *testing.T) {
+	var orders []*Order
+	loadFixture(t, "orders.json", &orders)
+
+	cases := []struct {
+		status        OrderStatus
+		tradeType     TradeType
+		price         string
+		amount        int
+		closingPrice  string
+		closingAmount int
+		orderTime     string
+		closingTime   string
+		final         bool
+		revocable     bool
+	}{
+		{OrderStatusUnreported, TradeTypeBuy, "3.856", 1000, "0", 0, "2023-11-21 09:30:15", "", false, true},
+		{OrderStatusReported, TradeTypeSale, "2.345", 100, "0", 0, "2023-11-21 09:30:16", "", false, true},
+		{OrderStatusPartFilled, TradeTypeBuy, "3.856", 1000, "3.855", 300, "2023-11-21 10:01:05", "2023-11-21 10:01:07", false, true},
+		{OrderStatusFilled, TradeTypeBuy, "7.12", 200, "7.11", 200, "2023-11-21 13:00:00", "2023-11-21 13:00:01", true, false},
+		{OrderStatusRevoked, TradeTypeSale, "7.2", 200, "0", 0, "2023-11-21 14:05:06", "", true, false},
+		{OrderStatusPartFillRevoked, TradeTypeSale, "2.35", 1000, "2.35", 400, "2023-11-21 14:45:00", "2023-11-21 14:45:01", true, false},
+		{OrderStatusRejected, TradeTypeBuy, "12.5", 100, "0", 0, "2023-11-21 15:00:00", "", true, false},
+		// 新股申购：方向无法判断，数量和价格无法解析时为0
+		{OrderStatusReported, "", "0", 0, "0", 0, "2023-11-21 09:15:00", "", false, true},
+	}
+	if len(orders) != len(cases) {
+		t.Fatalf("应该解析出 %d 条委托，实际 %d 条", len(cases), len(orders))
+	}
+	for i, c := range cases {
+		o := orders[i]
+		if got := o.OrderStatus(); got != c.status {
+			t.Errorf("%s: OrderStatus = %q，应该为 %q", o.OrderId, got, c.status)
+		}
+		if got := o.OrderStatus().IsFinal(); got != c.final {
+			t.Errorf("%s: IsFinal = %v，应该为 %v", o.OrderId, got, c.final)
+		}
+		if got := o.OrderStatus().IsRevocable(); got != c.revocable {
+			t.Errorf("%s: IsRevocable = %v，应该为 %v", o.OrderId, got, c.revocable)
+		}
+		if got := o.TradeType(); got != c.tradeType {
+			t.Errorf("%s: TradeType(%q) = %q，应该为 %q", o.OrderId, o.Type, got, c.tradeType)
+		}
+		if got := o.OrderPrice(); !got.Equal(decimal.RequireFromString(c.price)) {
+			t.Errorf("%s: OrderPrice = %s，应该为 %s", o.OrderId, got, c.price)
+		}
+		if got := o.Amount(); got != c.amount {
+			t.Errorf("%s: Amount(%q) = %d，应该为 %d", o.OrderId, o.AmountStr, got, c.amount)
+		}
+		if got := o.ClosingPrice(); !got.Equal(decimal.RequireFromString(c.closingPrice)) {
+			t.Errorf("%s: ClosingPrice = %s，应该为 %s", o.OrderId, got, c.closingPrice)
+		}
+		if got := o.ClosingAmount(); got != c.closingAmount {
+			t.Errorf("%s: ClosingAmount(%q) = %d，应该为 %d", o.OrderId, o.ClosingAmountStr, got, c.closingAmount)
+		}
+		if got := formatTime(o.OrderTime()); got != c.orderTime {
+			t.Errorf("%s: OrderTime(%q) = %q，应该为 %q", o.OrderId, o.Time, got, c.orderTime)
+		}
+		if got := formatTime(o.ClosingDateTime()); got != c.closingTime {
+			t.Errorf("%s: ClosingDateTime(%q) = %q，应该为 %q", o.OrderId, o.ClosingTime, got, c.closingTime)
+		}
+	}
+
+	// 委托时间为北京时间
+	if _, offset := orders[0].OrderTime().Zone(); offset != 8*3600 {
+		t.Errorf("委托时间的时区偏移为 %d，应该为北京时间", offset)
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func TestAccountAccessors(t *testing.T) {
+	var accounts []AccountDetail
+	loadFixture(t, "asset.json", &accounts)
+	if len(accounts) != 1 {
+		t.Fatalf("应该解析出1个账户，实际 %d 个", len(accounts))
+	}
+	a := accounts[0]
+	decimals := []struct {
+		name string
+		got  decimal.Decimal
+		want string
+	}{
+		{"TotalAsset", a.TotalAsset(), "105234.80"},
+		{"AvailableFund", a.AvailableFund(), "61234.50"},
+		{"FundBalance", a.FundBalance(), "62234.50"},
+		{"WithdrawableFund", a.WithdrawableFund(), "60234.50"},
+		{"FrozenFund", a.FrozenFund(), "1000"},
+		{"MarketValue", a.MarketValue(), "43000.30"},
+		{"ProfitLoss", a.ProfitLoss(), "-1230.70"},
+		{"TodayProfitLoss", a.TodayProfitLoss(), "56.78"},
+	}
+	for _, d := range decimals {
+		if !d.got.Equal(decimal.RequireFromString(d.want)) {
+			t.Errorf("%s = %s，应该为 %s", d.name, d.got, d.want)
+		}
+	}
+
+	if len(a.Positions) != 2 {
+		t.Fatalf("应该解析出2个持仓，实际 %d 个", len(a.Positions))
+	}
+	p := a.Positions[0]
+	if p.Code != "510300" || p.Market != "HA" {
+		t.Errorf("持仓代码或市场错误: %s %s", p.Code, p.Market)
+	}
+	if p.AvailableQuantity() != 10000 || p.TotalQuantity() != 10000 {
+		t.Errorf("持仓数量错误: %d %d", p.AvailableQuantity(), p.TotalQuantity())
+	}
+	positionDecimals := []struct {
+		name string
+		got  decimal.Decimal
+		want string
+	}{
+		{"CostPrice", p.CostPrice(), "3.95"},
+		{"LatestPrice", p.LatestPrice(), "3.856"},
+		{"MarketValue", p.MarketValue(), "38560"},
+		{"ProfitLoss", p.ProfitLoss(), "-940"},
+		{"ProfitLossRatio", p.ProfitLossRatio(), "-0.0238"},
+		{"TodayProfitLoss", p.TodayProfitLoss(), "50"},
+	}
+	for _, d := range positionDecimals {
+		if !d.got.Equal(decimal.RequireFromString(d.want)) {
+			t.Errorf("持仓 %s = %s，应该为 %s", d.name, d.got, d.want)
+		}
+	}
+	// 可用数量为0、持仓数量带小数
+	if q := a.Positions[1]; q.AvailableQuantity() != 0 || q.TotalQuantity() != 1900 {
+		t.Errorf("持仓 %s 数量错误: %d %d", q.Code, q.AvailableQuantity(), q.TotalQuantity())
+	}
+}
+
+func TestParseNumbers(t *testing.T) {
+	ints := map[string]int{"100": 100, "100.00": 100, " 200 ": 200, "1900.50": 1900, "": 0, "--": 0, "abc": 0}
+	for s, want := range ints {
+		if got := parseInt(s); got != want {
+			t.Errorf("parseInt(%q) = %d，应该为 %d", s, got, want)
+		}
+	}
+	decimals := map[string]string{"3.856": "3.856", " 7.10 ": "7.1", "-0.0238": "-0.0238", "": "0", "--": "0"}
+	for s, want := range decimals {
+		if got := parseDecimal(s); !got.Equal(decimal.RequireFromString(want)) {
+			t.Errorf("parseDecimal(%q) = %s，应该为 %s", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/yfjiang-danny/eastmoneyapi/errors"
+)
+
+// StatusCode 东财接口返回的状态码，有的接口返回数字，有的接口返回字符串
+type StatusCode int
+
+func (s *StatusCode) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*s = 0
+		return nil
+	}
+	v, err := strconv.Atoi(string(data))
+	if err != nil {
+		return err
+	}
+	*s = StatusCode(v)
+	return nil
+}
+
+// Response 东财接口的通用响应
+type Response struct {
+	Status  StatusCode      `json:"Status"`
+	Errcode StatusCode      `json:"Errcode"`
+	Message string          `json:"Message"`
+	Data    json.RawMessage `json:"Data"`
+}
+
+// Err 接口返回的错误，Status 为0时返回 nil
+func (r *Response) Err() error {
+	return errors.Parse(int(r.Status), int(r.Errcode), r.Message)
+}
+
+// Bind 将 Data 解析到 v 中，接口返回错误时直接返回该错误
+func (r *Response) Bind(v interface{}) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if len(r.Data) == 0 || v == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Data, v)
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Snapshot 账户快照，根据 queryAssetAndPositionV1 的返回数据计算持仓权重和总敞口，不需要额外查询行情
+type Snapshot struct {
+	TotalAsset  decimal.Decimal // 总资产
+	Cash        decimal.Decimal // 资金余额
+	MarketValue decimal.Decimal // 持仓总市值
+	Exposure    decimal.Decimal // 总敞口，持仓总市值 / 总资产
+	Positions   []PositionWeight
+}
+
+// PositionWeight 单个持仓的市值和权重
+type PositionWeight struct {
+	Code        string
+	Name        string
+	Market      string
+	Quantity    int
+	MarketValue decimal.Decimal
+	Weight      decimal.Decimal // 持仓市值 / 总资产
+}
+
+// Snapshot 计算账户快照。持仓没有返回最新市值时，使用最新价（没有最新价时使用成本价）乘以持仓数量估算；
+// 总资产为0时权重和敞口都为0
+func (a *AccountDetail) Snapshot() *Snapshot {
+	snapshot := &Snapshot{
+		TotalAsset: a.TotalAsset(),
+		Cash:       a.FundBalance(),
+		Positions:  make([]PositionWeight, 0, len(a.Positions)),
+	}
+	for i := range a.Positions {
+		p := &a.Positions[i]
+		value := p.MarketValue()
+		if value.IsZero() {
+			price := p.LatestPrice()
+			if price.IsZero() {
+				price = p.CostPrice()
+			}
+			value = price.Mul(decimal.NewFromInt(int64(p.TotalQuantity())))
+		}
+		snapshot.MarketValue = snapshot.MarketValue.Add(value)
+		snapshot.Positions = append(snapshot.Positions, PositionWeight{
+			Code:        p.Code,
+			Name:        p.Name,
+			Market:      p.Market,
+			Quantity:    p.TotalQuantity(),
+			MarketValue: value,
+		})
+	}
+	if snapshot.TotalAsset.IsPositive() {
+		for i := range snapshot.Positions {
+			snapshot.Positions[i].Weight = snapshot.Positions[i].MarketValue.Div(snapshot.TotalAsset)
+		}
+		snapshot.Exposure = snapshot.MarketValue.Div(snapshot.TotalAsset)
+	}
+	return snapshot
+}
+
+// Weight 指定证券的持仓权重，没有持仓时返回0
+func (s *Snapshot) Weight(code string) decimal.Decimal {
+	for _, p := range s.Positions {
+		if p.Code == code {
+			return p.Weight
+		}
+	}
+	return decimal.Zero
+}
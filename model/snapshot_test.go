@@ -0,0 +1,60 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSnapshot(t *testing.T) {
+	var accounts []AccountDetail
+	loadFixture(t, "asset.json", &accounts)
+	s := accounts[0].Snapshot()
+
+	total := decimal.RequireFromString("105234.80")
+	if !s.TotalAsset.Equal(total) || !s.Cash.Equal(decimal.RequireFromString("62234.50")) {
+		t.Fatalf("总资产或资金余额错误: %s %s", s.TotalAsset, s.Cash)
+	}
+	if !s.MarketValue.Equal(decimal.RequireFromString("43000.30")) {
+		t.Fatalf("持仓总市值错误: %s", s.MarketValue)
+	}
+	if want := decimal.RequireFromString("43000.30").Div(total); !s.Exposure.Equal(want) {
+		t.Fatalf("总敞口为 %s，应该为 %s", s.Exposure, want)
+	}
+	if want := decimal.RequireFromString("38560.00").Div(total); !s.Weight("510300").Equal(want) {
+		t.Fatalf("510300 的权重为 %s，应该为 %s", s.Weight("510300"), want)
+	}
+	if len(s.Positions) != 2 || s.Positions[1].Code != "159915" || s.Positions[1].Quantity != 1900 || s.Positions[1].Market != "SA" {
+		t.Fatalf("持仓错误: %+v", s.Positions)
+	}
+	if !s.Weight("600000").IsZero() {
+		t.Fatal("没有持仓的证券权重应该为0")
+	}
+}
+
+func TestSnapshotEstimatesMarketValue(t *testing.T) {
+	a := &AccountDetail{
+		TotalAssetStr:  "10000",
+		FundBalanceStr: "5000",
+		Positions: []PositionDetail{
+			// 没有最新市值，使用最新价估算
+			{Code: "510300", TotalQuantityStr: "1000", LatestPriceStr: "3.000"},
+			// 没有最新价，使用成本价估算
+			{Code: "159915", TotalQuantityStr: "1000", CostPriceStr: "2.000"},
+		},
+	}
+	s := a.Snapshot()
+	if !s.Positions[0].MarketValue.Equal(decimal.NewFromInt(3000)) || !s.Positions[1].MarketValue.Equal(decimal.NewFromInt(2000)) {
+		t.Fatalf("估算的市值错误: %+v", s.Positions)
+	}
+	if !s.Exposure.Equal(decimal.RequireFromString("0.5")) || !s.Weight("510300").Equal(decimal.RequireFromString("0.3")) {
+		t.Fatalf("敞口或权重错误: %s %s", s.Exposure, s.Weight("510300"))
+	}
+
+	// 总资产为0时权重和敞口都为0
+	a.TotalAssetStr = "0"
+	s = a.Snapshot()
+	if !s.Exposure.IsZero() || !s.Weight("510300").IsZero() {
+		t.Fatalf("总资产为0时敞口和权重应该为0: %s %s", s.Exposure, s.Weight("510300"))
+	}
+}
@@ -0,0 +1,181 @@
+package model
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// FundFlow 资金流水
+type FundFlow struct {
+	Date         string          // 发生日期 20231121
+	Time         string          // 发生时间 093015
+	BusinessType string          // 业务名称，如 证券买入、证券卖出、银行转证券
+	Code         string          // 证券代码
+	Name         string          // 证券名称
+	Quantity     int             // 成交数量
+	Price        decimal.Decimal // 成交价格
+	Amount       decimal.Decimal // 发生金额，资金流出为负数
+	Commission   decimal.Decimal // 手续费（佣金）
+	StampTax     decimal.Decimal // 印花税
+	TransferFee  decimal.Decimal // 过户费
+	OtherFee     decimal.Decimal // 其他费用
+	Balance      decimal.Decimal // 发生后的资金余额
+	Dwc          string          // 定位串，用于翻页
+}
+
+// DeliveryStatement 交割单
+type DeliveryStatement struct {
+	Date         string          // 成交日期 20231121
+	Time         string          // 成交时间 093015
+	BusinessType string          // 业务名称
+	OrderId      string          // 委托编号
+	DealId       string          // 成交编号
+	Code         string          // 证券代码
+	Name         string          // 证券名称
+	Quantity     int             // 成交数量
+	Price        decimal.Decimal // 成交价格
+	DealAmount   decimal.Decimal // 成交金额
+	Amount       decimal.Decimal // 清算金额（发生金额），资金流出为负数
+	Commission   decimal.Decimal // 手续费（佣金）
+	StampTax     decimal.Decimal // 印花税
+	TransferFee  decimal.Decimal // 过户费
+	OtherFee     decimal.Decimal // 其他费用
+	Balance      decimal.Decimal // 发生后的资金余额
+	Dwc          string          // 定位串，用于翻页
+}
+
+// statementJson 资金流水和交割单接口返回的原始数据，数值都是字符串
+type statementJson struct {
+	Fsrq string `json:"Fsrq"` // 发生日期
+	Fssj string `json:"Fssj"` // 发生时间
+	Ywsm string `json:"Ywsm"` // 业务名称
+	Wtbh string `json:"Wtbh"` // 委托编号
+	Cjbh string `json:"Cjbh"` // 成交编号
+	Zqdm string `json:"Zqdm"`
+	Zqmc string `json:"Zqmc"`
+	Cjsl string `json:"Cjsl"` // 成交数量
+	Cjjg string `json:"Cjjg"` // 成交价格
+	Cjje string `json:"Cjje"` // 成交金额
+	Fsje string `json:"Fsje"` // 发生金额
+	Sxf  string `json:"Sxf"`  // 手续费
+	Yhs  string `json:"Yhs"`  // 印花税
+	Ghf  string `json:"Ghf"`  // 过户费
+	Qtfy string `json:"Qtfy"` // 其他费用
+	Zjye string `json:"Zjye"` // 资金余额
+	Dwc  string `json:"Dwc"`
+}
+
+func (f *FundFlow) UnmarshalJSON(data []byte) error {
+	var raw statementJson
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*f = FundFlow{
+		Date:         raw.Fsrq,
+		Time:         raw.Fssj,
+		BusinessType: raw.Ywsm,
+		Code:         raw.Zqdm,
+		Name:         raw.Zqmc,
+		Quantity:     parseInt(raw.Cjsl),
+		Price:        parseDecimal(raw.Cjjg),
+		Amount:       parseDecimal(raw.Fsje),
+		Commission:   parseDecimal(raw.Sxf),
+		StampTax:     parseDecimal(raw.Yhs),
+		TransferFee:  parseDecimal(raw.Ghf),
+		OtherFee:     parseDecimal(raw.Qtfy),
+		Balance:      parseDecimal(raw.Zjye),
+		Dwc:          raw.Dwc,
+	}
+	return nil
+}
+
+func (d *DeliveryStatement) UnmarshalJSON(data []byte) error {
+	var raw statementJson
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*d = DeliveryStatement{
+		Date:         raw.Fsrq,
+		Time:         raw.Fssj,
+		BusinessType: raw.Ywsm,
+		OrderId:      raw.Wtbh,
+		DealId:       raw.Cjbh,
+		Code:         raw.Zqdm,
+		Name:         raw.Zqmc,
+		Quantity:     parseInt(raw.Cjsl),
+		Price:        parseDecimal(raw.Cjjg),
+		DealAmount:   parseDecimal(raw.Cjje),
+		Amount:       parseDecimal(raw.Fsje),
+		Commission:   parseDecimal(raw.Sxf),
+		StampTax:     parseDecimal(raw.Yhs),
+		TransferFee:  parseDecimal(raw.Ghf),
+		OtherFee:     parseDecimal(raw.Qtfy),
+		Balance:      parseDecimal(raw.Zjye),
+		Dwc:          raw.Dwc,
+	}
+	return nil
+}
+
+// TotalFee 总费用
+func (d *DeliveryStatement) TotalFee() decimal.Decimal {
+	return d.Commission.Add(d.StampTax).Add(d.TransferFee).Add(d.OtherFee)
+}
+
+// FundFlows 资金流水列表，按发生时间排序
+type FundFlows []*FundFlow
+
+// ClosingBalance 最后一笔流水发生后的资金余额
+func (f FundFlows) ClosingBalance() decimal.Decimal {
+	if len(f) == 0 {
+		return decimal.Zero
+	}
+	return f[len(f)-1].Balance
+}
+
+// NetAmount 发生金额的合计
+func (f FundFlows) NetAmount() decimal.Decimal {
+	total := decimal.Zero
+	for _, flow := range f {
+		total = total.Add(flow.Amount)
+	}
+	return total
+}
+
+// CashReconciliation 资金对账结果
+type CashReconciliation struct {
+	StatementBalance decimal.Decimal // 资金流水的期末余额
+	AccountBalance   decimal.Decimal // 账户当前的资金余额
+	Difference       decimal.Decimal // AccountBalance - StatementBalance
+}
+
+// Reconcile 用资金流水的期末余额与账户当前的资金余额对账
+func (f FundFlows) Reconcile(account *AccountDetail) CashReconciliation {
+	statement := f.ClosingBalance()
+	balance := account.FundBalance()
+	return CashReconciliation{
+		StatementBalance: statement,
+		AccountBalance:   balance,
+		Difference:       balance.Sub(statement),
+	}
+}
+
+// parseDecimal 解析东财返回的数值字符串，空字符串或者格式错误时返回0
+func parseDecimal(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(strings.TrimSpace(s))
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
+
+// parseInt 解析东财返回的整数字符串，可能带有小数部分，如 "100.00"
+func parseInt(s string) int {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.Atoi(s); err == nil {
+		return v
+	}
+	return int(parseDecimal(s).IntPart())
+}
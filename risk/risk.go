@@ -0,0 +1,278 @@
+// Package risk 提交委托前的风控检查：申报数量、价格最小变动单位、涨跌停价格、单笔金额、单个证券的持仓上限、
+// 当日累计成交金额上限以及紧急停止交易的开关
+package risk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/api"
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Config 风控配置，各项为零值时不检查
+type Config struct {
+	// 紧急停止交易，开启后拒绝所有委托，运行时可以通过 Checker.SetKillSwitch 修改
+	KillSwitch bool
+	// 检查委托数量是否为整手（科创板为200股起，以1股递增）
+	CheckLotSize bool
+	// 每手的数量，默认100
+	LotSize int
+	// 检查委托价格是否为最小价格变动单位的整数倍
+	CheckPriceTick bool
+	// 检查委托价格是否在涨跌停价格之间，涨跌停价格根据行情的昨收价计算
+	CheckPriceLimit bool
+	// 涨跌幅限制，为0时根据证券代码和名称判断，见 PriceLimitRatio
+	PriceLimitRatio float64
+	// 单笔委托的最大金额
+	MaxOrderValue float64
+	// 单个证券买入后的最大持仓数量和最大持仓金额（按委托价格计算）
+	MaxPositionQuantity int
+	MaxPositionValue    float64
+	// 当日委托的最大累计金额（买入和卖出合计），只统计通过本检查器提交的委托
+	MaxDailyTurnover float64
+
+	// 查询行情，用于获取昨收价，默认为 api.GetQuoteContext
+	Quote QuoteFunc `mapstructure:"-"`
+}
+
+// QuoteFunc 查询证券的最新行情
+type QuoteFunc func(ctx context.Context, code string) (*model.Stockquote, error)
+
+// PositionFunc 查询证券的持仓，没有持仓时返回 nil, nil
+type PositionFunc func(ctx context.Context, code string) (*model.PositionDetail, error)
+
+// Rule 风控规则
+type Rule string
+
+const (
+	RuleKillSwitch    Rule = "kill_switch"
+	RuleLotSize       Rule = "lot_size"
+	RulePriceTick     Rule = "price_tick"
+	RulePriceLimit    Rule = "price_limit"
+	RuleOrderValue    Rule = "order_value"
+	RulePosition      Rule = "position"
+	RuleDailyTurnover Rule = "daily_turnover"
+)
+
+// ErrRejected 委托被风控拒绝，具体原因见 *Rejection
+var ErrRejected = errors.New("委托被风控拒绝")
+
+// Rejection 风控拒绝的原因，Limit 和 Actual 为触发规则的限制值和实际值（没有时为0）
+type Rejection struct {
+	Rule    Rule
+	Code    string
+	Message string
+	Limit   decimal.Decimal
+	Actual  decimal.Decimal
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("%s(%s) %s: %s", ErrRejected.Error(), r.Rule, r.Code, r.Message)
+}
+
+func (r *Rejection) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Checker 风控检查器，可以被多个协程同时使用
+type Checker struct {
+	config   Config
+	position PositionFunc
+
+	mu         sync.Mutex
+	killSwitch bool
+	// 当日已通过检查的委托金额，日期变化时清零
+	turnoverDate string
+	turnover     decimal.Decimal
+	now          func() time.Time
+}
+
+// NewChecker 创建风控检查器，position 用于查询持仓，在检查持仓上限和卖出余股时使用
+func NewChecker(c Config, position PositionFunc) *Checker {
+	if c.LotSize <= 0 {
+		c.LotSize = defaultLotSize
+	}
+	if c.Quote == nil {
+		c.Quote = api.GetQuoteContext
+	}
+	return &Checker{config: c, position: position, killSwitch: c.KillSwitch, now: time.Now}
+}
+
+// SetKillSwitch 开启或者关闭紧急停止交易
+func (c *Checker) SetKillSwitch(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.killSwitch = on
+}
+
+// KillSwitch 是否已经紧急停止交易
+func (c *Checker) KillSwitch() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.killSwitch
+}
+
+// Turnover 当日已通过检查的委托金额
+func (c *Checker) Turnover() decimal.Decimal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rollover()
+	return c.turnover
+}
+
+// Check 检查委托，不通过时返回 *Rejection。通过后委托金额计入当日累计金额，
+// 如果之后委托提交失败，需要调用 Release 退回。
+// 检查的是委托的原始价格，提交时会四舍五入价格的调用方应该先用 RoundPrice 处理
+func (c *Checker) Check(ctx context.Context, order model.TradeOrderForm) error {
+	if c.KillSwitch() {
+		return newRejection(RuleKillSwitch, order, "已紧急停止交易", decimal.Zero, decimal.Zero)
+	}
+	sell := order.TradeType == model.TradeTypeSale
+	value := order.Price.Mul(decimal.NewFromInt(int64(order.Amount)))
+
+	// 先检查不需要查询的规则
+	if c.config.CheckPriceTick {
+		tick := PriceTick(order.Code)
+		if !order.Price.Mod(tick).IsZero() {
+			return newRejection(RulePriceTick, order, "委托价格不是最小价格变动单位 "+tick.String()+" 的整数倍", tick, order.Price)
+		}
+	}
+	if c.config.MaxOrderValue > 0 {
+		limit := decimal.NewFromFloat(c.config.MaxOrderValue)
+		if value.GreaterThan(limit) {
+			return newRejection(RuleOrderValue, order, "单笔委托金额超过上限", limit, value)
+		}
+	}
+	if c.config.MaxDailyTurnover > 0 {
+		limit := decimal.NewFromFloat(c.config.MaxDailyTurnover)
+		if total := c.Turnover().Add(value); total.GreaterThan(limit) {
+			return newRejection(RuleDailyTurnover, order, "当日累计委托金额超过上限", limit, total)
+		}
+	}
+
+	// 需要查询持仓的规则，只在需要时查询一次
+	var position *model.PositionDetail
+	var positionLoaded bool
+	loadPosition := func() (*model.PositionDetail, error) {
+		if positionLoaded || c.position == nil {
+			return position, nil
+		}
+		p, err := c.position(ctx, order.Code)
+		if err != nil {
+			return nil, errors.Wrap(err, "风控查询持仓失败")
+		}
+		position, positionLoaded = p, true
+		return position, nil
+	}
+
+	if c.config.CheckLotSize && !validLot(order.Code, order.Amount, c.config.LotSize, false, 0) {
+		available := 0
+		if sell {
+			p, err := loadPosition()
+			if err != nil {
+				return err
+			}
+			if p != nil {
+				available = p.AvailableQuantity()
+			}
+		}
+		if !validLot(order.Code, order.Amount, c.config.LotSize, sell, available) {
+			lot, message := c.config.LotSize, fmt.Sprintf("委托数量必须为 %d 的整数倍", c.config.LotSize)
+			if IsSTAR(order.Code) {
+				lot, message = starMinQuantity, fmt.Sprintf("科创板委托数量不能小于 %d", starMinQuantity)
+			}
+			return newRejection(RuleLotSize, order, message, decimal.NewFromInt(int64(lot)), decimal.NewFromInt(int64(order.Amount)))
+		}
+	}
+
+	if c.config.CheckPriceLimit {
+		quote, err := c.config.Quote(ctx, order.Code)
+		if err != nil {
+			return errors.Wrap(err, "风控查询行情失败")
+		}
+		preClose := decimal.NewFromFloat(quote.PreClosePrice)
+		if preClose.IsPositive() {
+			ratio := PriceLimitRatio(order.Code, quote.Name)
+			if c.config.PriceLimitRatio > 0 {
+				ratio = decimal.NewFromFloat(c.config.PriceLimitRatio)
+			}
+			down, up := PriceLimits(preClose, ratio, PriceTick(order.Code))
+			if order.Price.GreaterThan(up) {
+				return newRejection(RulePriceLimit, order, "委托价格高于涨停价", up, order.Price)
+			}
+			if order.Price.LessThan(down) {
+				return newRejection(RulePriceLimit, order, "委托价格低于跌停价", down, order.Price)
+			}
+		}
+	}
+
+	if !sell && (c.config.MaxPositionQuantity > 0 || c.config.MaxPositionValue > 0) {
+		p, err := loadPosition()
+		if err != nil {
+			return err
+		}
+		quantity := order.Amount
+		if p != nil {
+			quantity += p.TotalQuantity()
+		}
+		if c.config.MaxPositionQuantity > 0 && quantity > c.config.MaxPositionQuantity {
+			return newRejection(RulePosition, order, "买入后的持仓数量超过上限",
+				decimal.NewFromInt(int64(c.config.MaxPositionQuantity)), decimal.NewFromInt(int64(quantity)))
+		}
+		if c.config.MaxPositionValue > 0 {
+			limit := decimal.NewFromFloat(c.config.MaxPositionValue)
+			if total := order.Price.Mul(decimal.NewFromInt(int64(quantity))); total.GreaterThan(limit) {
+				return newRejection(RulePosition, order, "买入后的持仓金额超过上限", limit, total)
+			}
+		}
+	}
+
+	// 查询期间可能有其他委托通过了检查，加锁后再检查一次累计金额
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.killSwitch {
+		return newRejection(RuleKillSwitch, order, "已紧急停止交易", decimal.Zero, decimal.Zero)
+	}
+	c.rollover()
+	if c.config.MaxDailyTurnover > 0 {
+		limit := decimal.NewFromFloat(c.config.MaxDailyTurnover)
+		if total := c.turnover.Add(value); total.GreaterThan(limit) {
+			return newRejection(RuleDailyTurnover, order, "当日累计委托金额超过上限", limit, total)
+		}
+	}
+	c.turnover = c.turnover.Add(value)
+	return nil
+}
+
+// Release 委托提交失败后退回 Check 计入的当日累计金额
+func (c *Checker) Release(order model.TradeOrderForm) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rollover()
+	c.turnover = c.turnover.Sub(order.Price.Mul(decimal.NewFromInt(int64(order.Amount))))
+	if c.turnover.IsNegative() {
+		c.turnover = decimal.Zero
+	}
+}
+
+// cst 交易日按北京时间计算
+var cst = time.FixedZone("CST", 8*3600)
+
+// rollover 日期（北京时间）变化时清零当日累计金额，调用方需要持有 mu
+func (c *Checker) rollover() {
+	today := c.now().In(cst).Format("20060102")
+	if c.turnoverDate != today {
+		c.turnoverDate = today
+		c.turnover = decimal.Zero
+	}
+}
+
+func newRejection(rule Rule, order model.TradeOrderForm, message string, limit, actual decimal.Decimal) error {
+	return &Rejection{Rule: rule, Code: order.Code, Message: message, Limit: limit, Actual: actual}
+}
@@ -0,0 +1,234 @@
+package risk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yfjiang-danny/eastmoneyapi/model"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func TestPriceTick(t *testing.T) {
+	cases := []struct {
+		code string
+		tick string
+	}{
+		{"600000", "0.01"},
+		{"000001", "0.01"},
+		{"300750", "0.01"},
+		{"688981", "0.01"},
+		{"510300", "0.001"},
+		{"588000", "0.001"},
+		{"159915", "0.001"},
+	}
+	for _, c := range cases {
+		if tick := PriceTick(c.code); !tick.Equal(decimal.RequireFromString(c.tick)) {
+			t.Errorf("%s 的最小价格变动单位为 %s，应该为 %s", c.code, tick, c.tick)
+		}
+	}
+	if p := RoundPrice("510300", decimal.RequireFromString("3.8564")); p.String() != "3.856" {
+		t.Errorf("基金价格四舍五入为 %s，应该为 3.856", p)
+	}
+	if p := RoundPrice("600000", decimal.RequireFromString("7.125")); p.String() != "7.13" {
+		t.Errorf("股票价格四舍五入为 %s，应该为 7.13", p)
+	}
+}
+
+func TestValidLot(t *testing.T) {
+	cases := []struct {
+		code      string
+		amount    int
+		sell      bool
+		available int
+		valid     bool
+	}{
+		{"600000", 100, false, 0, true},
+		{"600000", 1000, false, 0, true},
+		{"600000", 150, false, 0, false},
+		{"600000", 0, false, 0, false},
+		{"600000", -100, true, 0, false},
+		// 卖出时余股一次性卖出
+		{"600000", 150, true, 150, true},
+		{"600000", 150, true, 250, false},
+		{"688981", 200, false, 0, true},
+		{"688981", 201, false, 0, true},
+		{"688981", 100, false, 0, false},
+		{"689009", 150, false, 0, false},
+		{"689009", 250, false, 0, true},
+		{"688981", 150, true, 150, true},
+		{"688981", 150, true, 350, false},
+	}
+	for _, c := range cases {
+		if valid := validLot(c.code, c.amount, defaultLotSize, c.sell, c.available); valid != c.valid {
+			t.Errorf("validLot(%s, %d, sell=%v, available=%d) = %v，应该为 %v", c.code, c.amount, c.sell, c.available, valid, c.valid)
+		}
+	}
+}
+
+func TestBuyableLot(t *testing.T) {
+	cases := []struct {
+		code    string
+		amount  int
+		lotSize int
+		want    int
+	}{
+		{"600000", 663, 0, 600},
+		{"600000", 99, 100, 0},
+		{"600000", 0, 100, 0},
+		{"600000", 1500, 1000, 1000},
+		{"688981", 263, 100, 263},
+		{"688981", 200, 100, 200},
+		{"688981", 199, 100, 0},
+		{"689009", 150, 100, 0},
+	}
+	for _, c := range cases {
+		got := BuyableLot(c.code, c.amount, c.lotSize)
+		if got != c.want {
+			t.Errorf("BuyableLot(%s, %d, %d) = %d，应该为 %d", c.code, c.amount, c.lotSize, got, c.want)
+		}
+		if got > 0 && c.lotSize > 0 && !validLot(c.code, got, c.lotSize, false, 0) {
+			t.Errorf("BuyableLot(%s, %d, %d) = %d 不符合申报规则", c.code, c.amount, c.lotSize, got)
+		}
+	}
+}
+
+func TestPriceLimitRatio(t *testing.T) {
+	cases := []struct {
+		code  string
+		name  string
+		ratio string
+	}{
+		{"600000", "浦发银行", "0.1"},
+		{"000001", "平安银行", "0.1"},
+		{"600001", "*ST某某", "0.05"},
+		{"300750", "宁德时代", "0.2"},
+		{"688981", "中芯国际", "0.2"},
+		{"689009", "九号公司", "0.2"},
+		{"830799", "艾融软件", "0.3"},
+		{"510300", "沪深300ETF", "0.1"},
+		{"159919", "沪深300ETF", "0.1"},
+		{"588000", "科创50ETF", "0.2"},
+		{"588080", "", "0.2"},
+		{"159915", "创业板ETF", "0.2"},
+		{"159781", "双创50ETF", "0.2"},
+	}
+	for _, c := range cases {
+		if ratio := PriceLimitRatio(c.code, c.name); !ratio.Equal(decimal.RequireFromString(c.ratio)) {
+			t.Errorf("%s %s 的涨跌幅限制为 %s，应该为 %s", c.code, c.name, ratio, c.ratio)
+		}
+	}
+}
+
+func TestPriceLimits(t *testing.T) {
+	down, up := PriceLimits(decimal.RequireFromString("10.05"), decimal.New(10, -2), PriceTick("600000"))
+	if down.String() != "9.05" || up.String() != "11.06" {
+		t.Fatalf("涨跌停价格为 %s %s，应该为 9.05 11.06", down, up)
+	}
+	down, up = PriceLimits(decimal.RequireFromString("1.234"), decimal.New(20, -2), PriceTick("159915"))
+	if down.String() != "0.987" || up.String() != "1.481" {
+		t.Fatalf("涨跌停价格为 %s %s，应该为 0.987 1.481", down, up)
+	}
+}
+
+func order(code string, price string, amount int, tradeType model.TradeType) model.TradeOrderForm {
+	return model.TradeOrderForm{Code: code, Price: decimal.RequireFromString(price), Amount: amount, TradeType: tradeType}
+}
+
+func TestCheckRules(t *testing.T) {
+	quote := func(ctx context.Context, code string) (*model.Stockquote, error) {
+		return &model.Stockquote{Code: code, Name: "创业板ETF", PreClosePrice: 2.000}, nil
+	}
+	position := func(ctx context.Context, code string) (*model.PositionDetail, error) {
+		return &model.PositionDetail{Code: code, AvailableQuantityStr: "150", TotalQuantityStr: "950"}, nil
+	}
+	cases := []struct {
+		name   string
+		config Config
+		order  model.TradeOrderForm
+		rule   Rule
+	}{
+		{"整手", Config{CheckLotSize: true}, order("159915", "2.000", 100, model.TradeTypeBuy), ""},
+		{"不是整手", Config{CheckLotSize: true}, order("159915", "2.000", 150, model.TradeTypeBuy), RuleLotSize},
+		{"余股卖出", Config{CheckLotSize: true}, order("159915", "2.000", 150, model.TradeTypeSale), ""},
+		{"最小变动单位", Config{CheckPriceTick: true}, order("159915", "2.0005", 100, model.TradeTypeBuy), RulePriceTick},
+		{"创业板基金20%涨停以内", Config{CheckPriceLimit: true, Quote: quote}, order("159915", "2.400", 100, model.TradeTypeBuy), ""},
+		{"高于涨停价", Config{CheckPriceLimit: true, Quote: quote}, order("159915", "2.401", 100, model.TradeTypeBuy), RulePriceLimit},
+		{"低于跌停价", Config{CheckPriceLimit: true, Quote: quote}, order("159915", "1.599", 100, model.TradeTypeSale), RulePriceLimit},
+		{"单笔金额", Config{MaxOrderValue: 1000}, order("159915", "2.000", 600, model.TradeTypeBuy), RuleOrderValue},
+		{"持仓数量", Config{MaxPositionQuantity: 1000}, order("159915", "2.000", 100, model.TradeTypeBuy), RulePosition},
+		{"持仓金额", Config{MaxPositionValue: 2000}, order("159915", "2.000", 100, model.TradeTypeBuy), RulePosition},
+		{"卖出不检查持仓上限", Config{MaxPositionQuantity: 1000}, order("159915", "2.000", 100, model.TradeTypeSale), ""},
+		{"紧急停止", Config{KillSwitch: true}, order("159915", "2.000", 100, model.TradeTypeBuy), RuleKillSwitch},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := NewChecker(c.config, position).Check(context.Background(), c.order)
+			if c.rule == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var rejection *Rejection
+			if !errors.As(err, &rejection) || !errors.Is(err, ErrRejected) || rejection.Rule != c.rule {
+				t.Fatalf("应该被 %s 规则拒绝: %v", c.rule, err)
+			}
+		})
+	}
+}
+
+func TestTurnoverCheckRelease(t *testing.T) {
+	c := NewChecker(Config{MaxDailyTurnover: 12000}, nil)
+	// 北京时间 2023-11-21 23:00
+	now := time.Date(2023, 11, 21, 15, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	buy := order("510300", "4.000", 1500, model.TradeTypeBuy)
+	if err := c.Check(context.Background(), buy); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Check(context.Background(), buy); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Turnover().Equal(decimal.NewFromInt(12000)) {
+		t.Fatalf("当日累计金额为 %s，应该为 12000", c.Turnover())
+	}
+	err := c.Check(context.Background(), order("510300", "4.000", 100, model.TradeTypeSale))
+	var rejection *Rejection
+	if !errors.As(err, &rejection) || rejection.Rule != RuleDailyTurnover {
+		t.Fatalf("应该超过当日累计金额上限: %v", err)
+	}
+	if !c.Turnover().Equal(decimal.NewFromInt(12000)) {
+		t.Fatal("被拒绝的委托不应该计入当日累计金额")
+	}
+
+	c.Release(buy)
+	if !c.Turnover().Equal(decimal.NewFromInt(6000)) {
+		t.Fatalf("退回后当日累计金额为 %s，应该为 6000", c.Turnover())
+	}
+	c.Release(buy)
+	c.Release(buy)
+	if !c.Turnover().IsZero() {
+		t.Fatalf("退回后当日累计金额不应该小于0: %s", c.Turnover())
+	}
+
+	if err := c.Check(context.Background(), buy); err != nil {
+		t.Fatal(err)
+	}
+	// 北京时间 2023-11-22 00:30，UTC 日期没有变化也应该清零
+	now = time.Date(2023, 11, 21, 16, 30, 0, 0, time.UTC)
+	if !c.Turnover().IsZero() {
+		t.Fatalf("北京时间日期变化后累计金额应该清零: %s", c.Turnover())
+	}
+	if err := c.Check(context.Background(), buy); err != nil {
+		t.Fatal(err)
+	}
+	// 北京时间 2023-11-22 23:00，UTC 日期变化但北京时间是同一天
+	now = time.Date(2023, 11, 22, 15, 0, 0, 0, time.UTC)
+	if !c.Turnover().Equal(decimal.NewFromInt(6000)) {
+		t.Fatalf("同一个北京时间交易日的累计金额被清零: %s", c.Turnover())
+	}
+}
@@ -0,0 +1,102 @@
+package risk
+
+import (
+	"strings"
+
+	"github.com/yfjiang-danny/eastmoneyapi/util"
+
+	"github.com/shopspring/decimal"
+)
+
+// 默认每手的数量
+const defaultLotSize = 100
+
+// 科创板单笔申报数量不小于200股，超过部分以1股为单位递增
+const starMinQuantity = 200
+
+// IsSTAR 是否为科创板代码（688 股票和 689 存托凭证）
+func IsSTAR(code string) bool {
+	return strings.HasPrefix(code, "688") || strings.HasPrefix(code, "689")
+}
+
+// PriceTick 最小价格变动单位，基金为0.001，股票为0.01
+func PriceTick(code string) decimal.Decimal {
+	if util.IsEFT(code) {
+		return decimal.New(1, -3)
+	}
+	return decimal.New(1, -2)
+}
+
+// RoundPrice 按最小价格变动单位四舍五入委托价格
+func RoundPrice(code string, price decimal.Decimal) decimal.Decimal {
+	return price.Round(-PriceTick(code).Exponent())
+}
+
+// PriceLimitRatio 涨跌幅限制：科创板、创业板以及跟踪科创板、创业板指数的基金20%，北交所30%，ST 股票5%，其余10%
+func PriceLimitRatio(code, name string) decimal.Decimal {
+	switch {
+	case IsSTAR(code) || strings.HasPrefix(code, "30") || isGrowthBoardFund(code, name):
+		return decimal.New(20, -2)
+	case strings.HasPrefix(code, "8") || strings.HasPrefix(code, "4"):
+		return decimal.New(30, -2)
+	case strings.Contains(strings.ToUpper(name), "ST"):
+		return decimal.New(5, -2)
+	}
+	return decimal.New(10, -2)
+}
+
+// isGrowthBoardFund 是否为跟踪科创板、创业板指数的基金：沪市 588、589 开头的科创板基金，
+// 以及名称中包含科创、创业、双创的基金（如深市 159 开头的创业板 ETF）
+func isGrowthBoardFund(code, name string) bool {
+	if !util.IsEFT(code) {
+		return false
+	}
+	if strings.HasPrefix(code, "588") || strings.HasPrefix(code, "589") {
+		return true
+	}
+	for _, keyword := range []string{"科创", "创业", "双创"} {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// PriceLimits 根据昨收价计算涨停价和跌停价，按最小价格变动单位四舍五入
+func PriceLimits(preClose, ratio decimal.Decimal, tick decimal.Decimal) (down, up decimal.Decimal) {
+	places := -tick.Exponent()
+	one := decimal.NewFromInt(1)
+	up = preClose.Mul(one.Add(ratio)).Round(places)
+	down = preClose.Mul(one.Sub(ratio)).Round(places)
+	return down, up
+}
+
+// BuyableLot 将买入数量向下调整为符合申报规则的数量：科创板不小于200股，超过部分以1股递增；
+// 其他证券为 lotSize（不大于0时为100）的整数倍。调整后不足时返回0
+func BuyableLot(code string, amount, lotSize int) int {
+	if lotSize <= 0 {
+		lotSize = defaultLotSize
+	}
+	if IsSTAR(code) {
+		if amount < starMinQuantity {
+			return 0
+		}
+		return amount
+	}
+	if amount <= 0 {
+		return 0
+	}
+	return amount - amount%lotSize
+}
+
+// validLot 检查委托数量是否符合申报规则。
+// 买入必须为整手，科创板不小于200股；卖出时余股（不足一手或者科创板不足200股的部分）需要一次性卖出，available 为可用数量
+func validLot(code string, amount, lotSize int, sell bool, available int) bool {
+	if amount <= 0 {
+		return false
+	}
+	if IsSTAR(code) {
+		return amount >= starMinQuantity || (sell && amount == available)
+	}
+	return amount%lotSize == 0 || (sell && amount == available)
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -25,17 +26,33 @@ func GetMarket(code string) string {
 	panic("暂未支持的证券代码")
 }
 
-//
+// Retry 重试执行 fn，直到成功或达到重试次数，返回的错误包装了最后一次失败的原因
 func Retry(numberOfTimes int, fn func() error) error {
+	return RetryIf(context.Background(), numberOfTimes, fn, nil)
+}
+
+// RetryIf 与 Retry 相同，但 retryable 返回 false 时不再重试，直接返回该错误
+// ctx 被取消时停止重试，返回 ctx.Err()
+func RetryIf(ctx context.Context, numberOfTimes int, fn func() error, retryable func(error) bool) error {
 	var err error
 	for count := 0; count < numberOfTimes; count++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		err = fn()
 		if err == nil {
 			return nil
 		}
-		time.Sleep(time.Millisecond * 20 * time.Duration(count))
+		if retryable != nil && !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond * 20 * time.Duration(count)):
+		}
 	}
-	return fmt.Errorf("重试 %d 次后，执行依旧失败，最后一次失败的原因：%s", numberOfTimes, err.Error())
+	return fmt.Errorf("重试 %d 次后，执行依旧失败，最后一次失败的原因：%w", numberOfTimes, err)
 }
 
 // 上证基金代码以50、51、52开头，
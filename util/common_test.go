@@ -0,0 +1,43 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRetryIf(t *testing.T) {
+	errFatal := errors.New("fatal")
+	errTemp := errors.New("temp")
+
+	calls := 0
+	err := RetryIf(context.Background(), 3, func() error {
+		calls++
+		if calls < 2 {
+			return errTemp
+		}
+		return nil
+	}, nil)
+	if err != nil || calls != 2 {
+		t.Fatalf("第二次成功后应该返回 nil，实际 %v，调用 %d 次", err, calls)
+	}
+
+	calls = 0
+	err = RetryIf(context.Background(), 3, func() error { calls++; return errTemp }, nil)
+	if !errors.Is(err, errTemp) || calls != 3 {
+		t.Fatalf("应该重试3次并包装最后一次的错误，实际 %v，调用 %d 次", err, calls)
+	}
+
+	calls = 0
+	err = RetryIf(context.Background(), 3, func() error { calls++; return errFatal }, func(err error) bool { return err != errFatal })
+	if err != errFatal || calls != 1 {
+		t.Fatalf("不可重试的错误应该直接返回，实际 %v，调用 %d 次", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	err = RetryIf(ctx, 3, func() error { calls++; cancel(); return errTemp }, nil)
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Fatalf("ctx 取消后应该停止重试，实际 %v，调用 %d 次", err, calls)
+	}
+}
@@ -1,13 +1,19 @@
 package util
 
 import (
+	"context"
 	"errors"
 	"os/exec"
 )
 
 // 识别验证码
 func ImgOCR(path string) (string, error) {
-	output, err := exec.Command("python", "./util/ocr.py", path).CombinedOutput()
+	return ImgOCRContext(context.Background(), path)
+}
+
+// ImgOCRContext 识别验证码，ctx 被取消时结束 python 进程
+func ImgOCRContext(ctx context.Context, path string) (string, error) {
+	output, err := exec.CommandContext(ctx, "python", "./util/ocr.py", path).CombinedOutput()
 	if err != nil {
 		return "", errors.New(err.Error() + string(output))
 	}